@@ -0,0 +1,367 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// BatchError is returned by BatchWorkerFunc, when some tasks of batch failed. Keys are indexes of failed tasks
+// in batch, and values are their errors. Tasks not mentioned are considered processed.
+type BatchError map[int]error
+
+// Error lists errors of failed tasks
+func (be BatchError) Error() string {
+	indexes := make([]int, 0, len(be))
+	for i := range be {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	parts := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		parts = append(parts, fmt.Sprintf("task %v: %s", i, be[i]))
+	}
+	return fmt.Sprintf("%v tasks of batch failed: %s", len(be), strings.Join(parts, "; "))
+}
+
+// reserveBatchScript moves up to ARGV[1] first tasks of the highest priority levels into in-flight list
+// and returns them
+var reserveBatchScript = redis.NewScript(`
+local reserved = {}
+local count = tonumber(ARGV[1])
+for i = #KEYS, 2, -1 do
+	while #reserved < count do
+		local raw = redis.call('LMOVE', KEYS[i], KEYS[1], 'LEFT', 'RIGHT')
+		if not raw then
+			break
+		end
+		reserved[#reserved + 1] = raw
+	end
+end
+return reserved
+`)
+
+// checkBatches checks, if batches can be consumed with other settings of queue
+func (rq *RedisQueue) checkBatches() error {
+	if rq.rateLimit.Rate > 0 {
+		return fmt.Errorf("batches cannot be consumed with rate limit")
+	}
+	if rq.globalConcurrency > 0 {
+		return fmt.Errorf("batches cannot be consumed with global concurrency")
+	}
+	return nil
+}
+
+// reserveBatch atomically moves up to count first tasks of the highest priority levels into in-flight list
+// of this consumer in one round trip
+func (rq *RedisQueue) reserveBatch(initialCtx context.Context, count int) (raws []string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.reserveBatch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("count", count),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Int("found", len(raws)))
+		span.End()
+	}()
+	if rq.paused.Load() {
+		span.AddEvent("queue is paused")
+		return
+	}
+	raws, err = reserveBatchScript.Run(ctx, rq.client,
+		append([]string{rq.processingKey(rq.id)}, rq.levelKeys()...),
+		count,
+	).StringSlice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return
+}
+
+// wrapBatchWorker traces execution of batch worker and recovers its panic, so it is returned as PanicError
+func (rq *RedisQueue) wrapBatchWorker(input BatchWorkerFunc) BatchWorkerFunc {
+	return func(initialCtx context.Context, payloads []string, indx int) (err error) {
+		ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.batchWorker",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("queue", rq.name),
+				attribute.String("consumer.id", rq.GetID()),
+				attribute.Int("consumer.index", indx),
+				attribute.Int("consumer.batch_size", len(payloads)),
+			))
+		attachCodeLocationToSpan(span)
+		defer span.End()
+		defer recoverWorker(span, &err)
+		return input(ctx, payloads, indx)
+	}
+}
+
+// processBatch executes worker against batch of tasks reserved by this consumer, acknowledges tasks processed
+// and registers failed attempts of tasks failed. Expired and canceled tasks are not passed to worker.
+// If task is canceled, while batch is processed, context of worker is canceled with cause ErrTaskCanceled.
+func (rq *RedisQueue) processBatch(ctx context.Context, worker BatchWorkerFunc, raws []string, indx int) (err error) {
+	// tasks should be acknowledged even if consumer is stopping right now
+	ackCtx := context.WithoutCancel(ctx)
+	ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+	defer cancel()
+	batchCtx, cancelBatch := context.WithCancelCause(ctx)
+	defer cancelBatch(nil)
+	now := time.Now()
+	fresh := make([]string, 0, len(raws))
+	tasks := make([]Task, 0, len(raws))
+	payloads := make([]string, 0, len(raws))
+	dones := make([]func() bool, 0, len(raws))
+	for _, raw := range raws {
+		task := decodeTask(raw)
+		if rq.isExpired(task, now) {
+			err = rq.expire(ctx2, raw, task, true)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		// task is tracked apart from batch, so only tasks canceled themselves are considered canceled
+		taskCtx, done := rq.track(ctx, task.ID)
+		if errors.Is(context.Cause(taskCtx), ErrTaskCanceled) {
+			// task was canceled, while it was waiting for idle worker, and it is already removed from in-flight list
+			done()
+			err = rq.finish(ctx2, task, ResultCanceled, "")
+			if err != nil {
+				return
+			}
+			continue
+		}
+		context.AfterFunc(taskCtx, func() {
+			if errors.Is(context.Cause(taskCtx), ErrTaskCanceled) {
+				cancelBatch(ErrTaskCanceled)
+			}
+		})
+		fresh = append(fresh, raw)
+		tasks = append(tasks, task)
+		payloads = append(payloads, task.Payload)
+		dones = append(dones, done)
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+	lease, workerCtx := rq.newLease(withBatch(batchCtx, tasks), rq.timeout)
+	errW := rq.wrapBatchWorker(worker)(workerCtx, payloads, indx)
+	lease.release()
+
+	ctx3, cancel3 := context.WithTimeout(ackCtx, rq.timeout)
+	defer cancel3()
+	var be BatchError
+	isBatchError := errors.As(errW, &be)
+	for i := range fresh {
+		reason := errW
+		if isBatchError {
+			reason = be[i]
+		}
+		switch {
+		case dones[i]():
+			// canceled task is already removed from in-flight list
+			err = rq.finish(ctx3, tasks[i], ResultCanceled, "")
+		case reason != nil:
+			err = rq.fail(ctx3, fresh[i], tasks[i], reason)
+		default:
+			err = rq.complete(ctx3, fresh[i], tasks[i], "")
+		}
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// ConsumeBatches starts getting tasks from channel in batches, and processes them by concurrency+1 workers.
+// Batch is passed to worker, when it has maxBatch tasks, or when maxWait passed since first task of batch
+// was taken. Tasks are taken by up to maxBatch in one round trip and are delivered at least once,
+// like ones consumed by ConsumeConcurrently. Worker can report failures of individual tasks by BatchError,
+// and tasks of batch can be received by BatchFromContext. Batches cannot be consumed, when rate limit
+// or global concurrency is set, since they limit tasks, not batches.
+func (rq *RedisQueue) ConsumeBatches(initialCtx context.Context, worker BatchWorkerFunc, maxBatch int, maxWait time.Duration, concurrency int) (err error) {
+	err = rq.checkBatches()
+	if err != nil {
+		return
+	}
+	if maxBatch < 1 {
+		return fmt.Errorf("batch size %v should be positive", maxBatch)
+	}
+	if maxWait <= 0 {
+		return fmt.Errorf("batch linger time %s should be positive", maxWait)
+	}
+	rq.listener = redis.NewClient(rq.options)
+	err = rq.listener.Ping(initialCtx).Err()
+	if err != nil {
+		return
+	}
+	err = rq.presence(initialCtx)
+	if err != nil {
+		return
+	}
+	err = rq.syncPaused(initialCtx)
+	if err != nil {
+		return
+	}
+	// timer is armed to the earliest scheduled task
+	rq.due = make(chan struct{}, 1)
+	_, err = rq.promote(initialCtx)
+	if err != nil {
+		return
+	}
+	p := ChannelPrefix + rq.name
+	subscriber := rq.listener.Subscribe(initialCtx, p)
+	ticker := time.NewTicker(rq.heartbeat)
+	defer ticker.Stop()
+	presenceTicker := time.NewTicker(PresenceTimeout / 3)
+	defer presenceTicker.Stop()
+	linger := time.NewTimer(maxWait)
+	linger.Stop()
+	defer linger.Stop()
+	sb := subscriber.Channel()
+	feed := make(chan []string)
+	rq.wake = make(chan struct{}, 1)
+	rq.startedAt = time.Now()
+	rq.isConsumerRunning = true
+
+	eg, ctx := errgroup.WithContext(initialCtx)
+
+	eg.Go(func() error {
+		defer func() {
+			// cleanup should be performed even if consumer context is canceled
+			ctx2, cancel := context.WithTimeout(context.WithoutCancel(ctx), rq.timeout)
+			defer cancel()
+			rq.isConsumerRunning = false
+			subscriber.Unsubscribe(ctx2, p)
+			subscriber.Close()
+		}()
+		var pending []string
+		// flush passes pending batch to worker, while consumer keeps reporting its presence
+		flush := func() (stopped bool, err error) {
+			linger.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					// pending tasks are returned to queue, when consumer leaves
+					return true, nil
+				case feed <- pending:
+					pending = nil
+					return false, nil
+				case <-presenceTicker.C:
+					ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+					err = rq.housekeep(ctx2)
+					cancel()
+					if err != nil {
+						return true, err
+					}
+				}
+			}
+		}
+		// collect takes tasks from queue, until it is empty, and passes full batches to workers
+		collect := func() (stopped bool, err error) {
+			for {
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				raws, errR := rq.reserveBatch(ctx2, maxBatch-len(pending))
+				cancel()
+				if errR != nil {
+					return true, errR
+				}
+				if len(raws) == 0 {
+					return false, nil
+				}
+				if len(pending) == 0 {
+					linger.Reset(maxWait)
+				}
+				pending = append(pending, raws...)
+				if len(pending) < maxBatch {
+					return false, nil
+				}
+				stopped, err = flush()
+				if stopped {
+					return
+				}
+			}
+		}
+		for {
+			var stopped bool
+			var errL error
+			select {
+			case <-ctx.Done():
+				return nil
+			case msg := <-sb:
+				if !rq.control(msg.Payload) {
+					stopped, errL = collect()
+				}
+			case <-rq.wake:
+				stopped, errL = collect()
+			case <-ticker.C:
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				_, errL = rq.promote(ctx2)
+				cancel()
+				if errL == nil {
+					stopped, errL = collect()
+				}
+			case <-rq.due:
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				_, errL = rq.promote(ctx2)
+				cancel()
+			case <-linger.C:
+				if len(pending) > 0 {
+					stopped, errL = flush()
+				}
+			case <-presenceTicker.C:
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				errL = rq.housekeep(ctx2)
+				cancel()
+			}
+			if errL != nil {
+				return errL
+			}
+			if stopped {
+				return nil
+			}
+		}
+	})
+
+	for i := 0; i <= concurrency; i++ {
+		eg.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case batch := <-feed:
+					errW := rq.processBatch(ctx, worker, batch, i)
+					if errW != nil {
+						return errW
+					}
+				}
+			}
+		})
+	}
+
+	err = eg.Wait()
+	ctx3, cancel := context.WithTimeout(context.WithoutCancel(initialCtx), rq.timeout)
+	defer cancel()
+	errL := rq.leave(ctx3)
+	if errL != nil && err == nil {
+		err = errL
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_ConsumeBatches(t *testing.T) {
+	const tasks = 25
+	const maxBatch = 10
+	rq, err := New(t.Context(), "testBatches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < tasks; i++ {
+		_, err = rq.Publish(t.Context(), fmt.Sprintf("row %v", i))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var sizes []int
+	var failed string
+	inserted := make(map[string]int)
+	err = rq.ConsumeBatches(cc, func(ctx context.Context, payloads []string, indx int) error {
+		sizes = append(sizes, len(payloads))
+		if len(sizes) == 1 {
+			failed = payloads[3]
+			for i := range payloads {
+				if i != 3 {
+					inserted[payloads[i]]++
+				}
+			}
+			return BatchError{3: fmt.Errorf("constraint violation")}
+		}
+		for i := range payloads {
+			inserted[payloads[i]]++
+		}
+		if len(inserted) == tasks {
+			cancel()
+		}
+		return nil
+	}, maxBatch, 100*time.Millisecond, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if len(inserted) != tasks {
+		t.Errorf("wrong number of tasks processed %v", len(inserted))
+	}
+	for payload, n := range inserted {
+		if n != 1 {
+			t.Errorf("task %s is processed %v times", payload, n)
+		}
+	}
+	if _, found := inserted[failed]; !found {
+		t.Errorf("failed task %s is not retried", failed)
+	}
+	var total int
+	for i := range sizes {
+		if sizes[i] > maxBatch {
+			t.Errorf("batch %v is too big: %v", i, sizes[i])
+		}
+		total += sizes[i]
+	}
+	if total != tasks+1 {
+		t.Errorf("wrong number of tasks %v delivered in batches %v", total, sizes)
+	}
+	if sizes[0] != maxBatch {
+		t.Errorf("first batch is not full: %v", sizes)
+	}
+	if sizes[len(sizes)-1] == maxBatch {
+		t.Errorf("last batch is not delivered by linger time: %v", sizes)
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("%v tasks are left in queue", n)
+	}
+}
+
+func TestBatchError_Error(t *testing.T) {
+	be := BatchError{2: fmt.Errorf("second"), 0: fmt.Errorf("first")}
+	if be.Error() != "2 tasks of batch failed: task 0: first; task 2: second" {
+		t.Errorf("wrong error %s", be.Error())
+	}
+}
+
+func TestRedisQueue_ConsumeBatchesCancel(t *testing.T) {
+	rq, err := New(t.Context(), "testBatchesCancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	ids, err := rq.PublishBatch(t.Context(), "a", "b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	started := make(chan struct{})
+	var cause error
+	var retried []string
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- rq.ConsumeBatches(cc, func(ctx context.Context, payloads []string, indx int) error {
+			batch, found := BatchFromContext(ctx)
+			if !found || len(batch) != len(payloads) {
+				t.Errorf("wrong tasks %v of batch %v", batch, payloads)
+			}
+			if cause == nil {
+				close(started)
+				<-ctx.Done()
+				cause = context.Cause(ctx)
+				return ctx.Err()
+			}
+			retried = payloads
+			cancel()
+			return nil
+		}, 3, 100*time.Millisecond, 0)
+	}()
+	select {
+	case <-started:
+	case <-cc.Done():
+		t.Fatalf("batch is not started")
+	}
+	result, err := rq.Cancel(t.Context(), ids[1])
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelRunning {
+		t.Errorf("task of running batch is %s", result)
+	}
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if !errors.Is(cause, ErrTaskCanceled) {
+		t.Errorf("batch worker context is canceled by %v", cause)
+	}
+	if fmt.Sprint(retried) != "[a c]" {
+		t.Errorf("wrong tasks %v are retried after cancel", retried)
+	}
+	n, err := rq.CountDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("canceled task is moved to dead letter queue")
+	}
+
+	rq.SetRateLimit(RateLimit{Rate: 1})
+	err = rq.ConsumeBatches(t.Context(), func(ctx context.Context, payloads []string, indx int) error {
+		return nil
+	}, 3, time.Second, 0)
+	if err == nil {
+		t.Errorf("batches are consumed with rate limit")
+	}
+	rq.SetRateLimit(RateLimit{})
+	rq.SetGlobalConcurrency(1)
+	err = rq.ConsumeBatches(t.Context(), func(ctx context.Context, payloads []string, indx int) error {
+		return nil
+	}, 3, time.Second, 0)
+	if err == nil {
+		t.Errorf("batches are consumed with global concurrency")
+	}
+}
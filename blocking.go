@@ -0,0 +1,145 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// blockingTimeout limits duration of one blocking call, so dead connections are noticed
+const blockingTimeout = PresenceTimeout / 3
+
+// unblockRetry is interval between attempts to interrupt blocking call, which is not received by redis yet
+const unblockRetry = 10 * time.Millisecond
+
+// SetBlocking enables blocking mode of ConsumeConcurrently. In this mode consumer waits for tasks of normal
+// priority by BLMOVE, so tasks pushed by other clients without notification, like `redis-cli rpush`,
+// are taken instantly, and heartbeat can be long, since scheduled tasks are moved to queue by timer,
+// when they are due.
+// Notifications about tasks of other priority levels interrupt blocking call via CLIENT UNBLOCK,
+// so these tasks are taken right away too, while heartbeat only moves scheduled tasks to queue.
+// Blocking mode cannot be combined with rate limit and global concurrency.
+func (rq *RedisQueue) SetBlocking(blocking bool) {
+	rq.blocking = blocking
+}
+
+// checkBlocking checks, if blocking mode can be used with other settings of queue
+func (rq *RedisQueue) checkBlocking() error {
+	if !rq.blocking {
+		return nil
+	}
+	if rq.rateLimit.Rate > 0 {
+		return fmt.Errorf("blocking mode cannot be used with rate limit")
+	}
+	if rq.globalConcurrency > 0 {
+		return fmt.Errorf("blocking mode cannot be used with global concurrency")
+	}
+	return nil
+}
+
+// block reserves tasks and passes them to workers via unbuffered handoff, waiting for new tasks of normal priority
+// by BLMOVE, when queue is empty. Next task is reserved only after previous one is taken by worker, so busy consumer
+// holds at most one task, which is not processed, and tasks are left in queue for other consumers.
+// Notifications received via notify interrupt blocking call, so tasks of all priority levels are reserved again.
+// Blocking call is interrupted by closing dedicated connection, when context is canceled.
+func (rq *RedisQueue) block(ctx context.Context, handoff chan<- string, notify <-chan struct{}) (err error) {
+	blocker := redis.NewClient(rq.options)
+	stop := context.AfterFunc(ctx, func() {
+		blocker.Close()
+	})
+	defer func() {
+		if stop() {
+			blocker.Close()
+		}
+	}()
+	conn := blocker.Conn()
+	blockerID, err := conn.ClientID(ctx).Result()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return
+	}
+	// waiting is true since reserving task till the end of blocking call, so notification received
+	// in between is not lost
+	var waiting atomic.Bool
+	unblockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go rq.unblock(unblockCtx, blockerID, &waiting, notify)
+	var raw string
+	var found bool
+	for {
+		if rq.paused.Load() {
+			select {
+			case <-rq.resumed:
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+		waiting.Store(true)
+		// tasks of higher priority levels are reserved first
+		ctx2, cancel2 := context.WithTimeout(ctx, rq.timeout)
+		raw, found, err = rq.reserve(ctx2)
+		cancel2()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return
+		}
+		if !found {
+			raw, err = conn.BLMove(ctx, rq.name, rq.processingKey(rq.id), "LEFT", "RIGHT", blockingTimeout).Result()
+			waiting.Store(false)
+			if ctx.Err() != nil {
+				// task, that was moved, when connection was closed, is returned to queue, when consumer leaves
+				return nil
+			}
+			if err == redis.Nil {
+				// blocking call is timed out or interrupted by notification
+				continue
+			}
+			if err != nil {
+				return
+			}
+			if rq.paused.Load() {
+				// queue was paused, while consumer was waiting for task
+				err = rq.unreserve(ctx, raw)
+				if err != nil {
+					return
+				}
+				continue
+			}
+		}
+		waiting.Store(false)
+		select {
+		case handoff <- raw:
+		case <-ctx.Done():
+			// task is returned to queue, when consumer leaves
+			return nil
+		}
+	}
+}
+
+// unblock interrupts blocking call of connection with id provided, when consumer is notified about new task,
+// so tasks of other priority levels do not wait, until blocking call is timed out
+func (rq *RedisQueue) unblock(ctx context.Context, blockerID int64, waiting *atomic.Bool, notify <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		}
+		// notification can be received before blocking call reaches redis
+		for waiting.Load() && ctx.Err() == nil {
+			n, err := rq.client.ClientUnblock(ctx, blockerID).Result()
+			if err != nil || n > 0 {
+				break
+			}
+			time.Sleep(unblockRetry)
+		}
+	}
+}
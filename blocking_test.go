@@ -0,0 +1,172 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_SetBlocking(t *testing.T) {
+	rq, err := New(t.Context(), "testBlocking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetBlocking(true)
+	// consumer never polls queue by itself
+	rq.SetHeartbeat(time.Hour)
+
+	pushed := make(chan time.Time, 1)
+	time.AfterFunc(200*time.Millisecond, func() {
+		pushed <- time.Now()
+		// task is pushed without notification, like redis-cli does
+		errP := rq.client.RPush(context.Background(), rq.GetQueueName(), "1419719").Err()
+		if errP != nil {
+			t.Error(errP)
+		}
+	})
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var receivedAt, stoppedAt time.Time
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		if payload != "1419719" {
+			t.Errorf("wrong payload %s", payload)
+		}
+		receivedAt = time.Now()
+		stoppedAt = time.Now()
+		cancel()
+		return nil
+	}, 1)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if receivedAt.IsZero() {
+		t.Fatalf("task is not received")
+	}
+	pushedAt := <-pushed
+	if receivedAt.Sub(pushedAt) > time.Second {
+		t.Errorf("task is received too late, after %s", receivedAt.Sub(pushedAt))
+	}
+	if time.Since(stoppedAt) > time.Second {
+		t.Errorf("blocked consumer is stopped too slowly, in %s", time.Since(stoppedAt))
+	}
+	inFlight, err := rq.client.LLen(t.Context(), rq.processingKey(rq.GetID())).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 0 {
+		t.Errorf("%v tasks are left in flight", inFlight)
+	}
+
+	rq.SetRateLimit(RateLimit{Rate: 1})
+	err = rq.ConsumeConcurrently(t.Context(), func(ctx context.Context, payload string, indx int) error {
+		return nil
+	}, 1)
+	if err == nil {
+		t.Errorf("blocking mode is combined with rate limit")
+	}
+}
+
+func TestRedisQueue_SetBlockingBusy(t *testing.T) {
+	rq, err := New(t.Context(), "testBlockingBusy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetBlocking(true)
+	rq.SetHeartbeat(time.Hour)
+	for i := 0; i < 20; i++ {
+		err = rq.client.RPush(t.Context(), rq.GetQueueName(), "task").Err()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	mu := sync.Mutex{}
+	processed := 0
+	var maxInFlight int64
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		time.Sleep(50 * time.Millisecond)
+		inFlight, errL := rq.client.LLen(t.Context(), rq.processingKey(rq.GetID())).Result()
+		if errL != nil {
+			t.Error(errL)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		maxInFlight = max(maxInFlight, inFlight)
+		processed++
+		if processed == 6 {
+			cancel()
+		}
+		return nil
+	}, 1)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	// 2 workers are busy, and one more task waits for them
+	if maxInFlight > 3 {
+		t.Errorf("busy consumer holds %v tasks", maxInFlight)
+	}
+}
+
+func TestRedisQueue_SetBlockingPriority(t *testing.T) {
+	publisher, err := New(t.Context(), "testBlockingPriority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq, err := New(t.Context(), "testBlockingPriority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	rq.SetBlocking(true)
+	// consumer never polls queue by itself
+	rq.SetHeartbeat(time.Hour)
+
+	pushed := make(chan time.Time, 1)
+	time.AfterFunc(500*time.Millisecond, func() {
+		pushed <- time.Now()
+		// blocking call waits for tasks of normal priority only
+		_, errP := publisher.PublishWithPriority(context.Background(), PriorityHigh, "urgent")
+		if errP != nil {
+			t.Error(errP)
+		}
+	})
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var receivedAt time.Time
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		if payload != "urgent" {
+			t.Errorf("wrong payload %s", payload)
+		}
+		receivedAt = time.Now()
+		cancel()
+		return nil
+	}, 1)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if receivedAt.IsZero() {
+		t.Fatalf("task is not received")
+	}
+	pushedAt := <-pushed
+	if receivedAt.Sub(pushedAt) > 500*time.Millisecond {
+		t.Errorf("task of high priority is received too late, after %s", receivedAt.Sub(pushedAt))
+	}
+}
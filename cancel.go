@@ -0,0 +1,206 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrTaskCanceled is cause of context of worker, which task was canceled by Cancel
+var ErrTaskCanceled = fmt.Errorf("task is canceled")
+
+// cancelMessagePrefix starts message published to channel of queue, when running task is canceled.
+// Message is followed by task id, colon and id of consumer running it.
+const cancelMessagePrefix = "grq:cancel:"
+
+// CancelResult shows, what happened to task canceled by Cancel
+type CancelResult int
+
+const (
+	// CancelNotFound means task is not found in queue, in set of scheduled tasks and in in-flight lists,
+	// so it is already processed, or it never existed
+	CancelNotFound CancelResult = iota
+	// CancelPending means task was waiting in queue or in set of scheduled tasks, and it is removed from there
+	CancelPending
+	// CancelRunning means task was reserved by consumer, so it is removed from in-flight list of consumer,
+	// and consumer is notified to cancel context of worker processing it
+	CancelRunning
+)
+
+// String returns name of cancel result
+func (r CancelResult) String() string {
+	switch r {
+	case CancelNotFound:
+		return "not found"
+	case CancelPending:
+		return "pending"
+	case CancelRunning:
+		return "running"
+	default:
+		return "unknown"
+	}
+}
+
+// cancelScript removes task with id provided from lists of priority levels, from set of scheduled tasks or
+// from in-flight lists of consumers, and notifies consumer, if task was reserved by it. Code of CancelResult
+// is returned with task removed from queue, or with empty string, if task was not pending.
+// KEYS are set of scheduled tasks, lists of priority levels, and in-flight lists of consumers,
+// which ids are passed in ARGV after task id, channel and number of priority levels.
+var cancelScript = redis.NewScript(fmt.Sprintf(`
+local id = ARGV[1]
+local levels = tonumber(ARGV[3])
+local function matches(raw)
+	if string.sub(raw, 1, %d) ~= '%s' or not string.find(raw, id, 1, true) then
+		return false
+	end
+	local ok, task = pcall(cjson.decode, raw)
+	return ok and type(task) == 'table' and task.id == id
+end
+local function find(key)
+	for _, raw in ipairs(redis.call('LRANGE', key, 0, -1)) do
+		if matches(raw) then
+			redis.call('LREM', key, 1, raw)
+			return raw
+		end
+	end
+	return nil
+end
+for i = 2, levels + 1 do
+	local raw = find(KEYS[i])
+	if raw then
+		return {1, raw}
+	end
+end
+local prefix = id .. ':'
+for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+	if string.sub(member, 1, #prefix) == prefix then
+		redis.call('ZREM', KEYS[1], member)
+		return {1, string.sub(member, #prefix + 1)}
+	end
+end
+for i = levels + 2, #KEYS do
+	if find(KEYS[i]) then
+		local consumer = ARGV[i - levels + 2]
+		redis.call('PUBLISH', ARGV[2], '%s' .. id .. ':' .. consumer)
+		return {2, ''}
+	end
+end
+return {0, ''}
+`, len(envelopePrefix), envelopePrefix, cancelMessagePrefix))
+
+// Cancel removes task with id provided from queue or from set of scheduled tasks. If task is already reserved
+// by consumer, it is removed from in-flight list of consumer, and context of worker processing it
+// is canceled with cause ErrTaskCanceled, so task is neither retried nor moved to dead letter queue.
+// Context of worker of ConsumeBatches is canceled with the same cause, when any task of its batch is canceled.
+// Chain, which canceled task is step of, is stopped.
+// Cancel scans all lists of queue, so it is slow for long queues.
+func (rq *RedisQueue) Cancel(initialCtx context.Context, taskID string) (result CancelResult, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Cancel",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("task.id", taskID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.String("result", result.String()))
+		span.End()
+	}()
+	if taskID == "" {
+		return CancelNotFound, fmt.Errorf("task id is empty")
+	}
+	consumers, err := rq.client.ZRange(ctx, rq.key("consumers"), 0, -1).Result()
+	if err != nil {
+		return
+	}
+	levels := rq.levelKeys()
+	keys := append([]string{rq.key("scheduled")}, levels...)
+	args := []any{taskID, ChannelPrefix + rq.name, strconv.Itoa(len(levels))}
+	for _, consumerID := range consumers {
+		keys = append(keys, rq.processingKey(consumerID))
+		args = append(args, consumerID)
+	}
+	res, err := cancelScript.Run(ctx, rq.client, keys, args...).Slice()
+	if err != nil {
+		return
+	}
+	if len(res) != 2 {
+		return CancelNotFound, fmt.Errorf("unexpected reply %v of cancel script", res)
+	}
+	code, _ := res[0].(int64)
+	raw, _ := res[1].(string)
+	result = CancelResult(code)
+	if result == CancelPending {
+		// result of running task is stored by consumer, when worker returns
+		err = rq.finish(ctx, decodeTask(raw), ResultCanceled, "")
+	}
+	return
+}
+
+// track registers task being processed by this consumer, so it can be canceled. Returned function
+// unregisters task and reports, if it was canceled.
+func (rq *RedisQueue) track(ctx context.Context, taskID string) (tracked context.Context, done func() (canceled bool)) {
+	tracked, cancel := context.WithCancelCause(ctx)
+	if taskID != "" {
+		rq.runningMu.Lock()
+		if _, ok := rq.canceled[taskID]; ok {
+			// task was canceled, while it was waiting for idle worker
+			delete(rq.canceled, taskID)
+			cancel(ErrTaskCanceled)
+		} else {
+			rq.running[taskID] = cancel
+		}
+		rq.runningMu.Unlock()
+	}
+	return tracked, func() bool {
+		rq.runningMu.Lock()
+		delete(rq.running, taskID)
+		rq.runningMu.Unlock()
+		canceled := errors.Is(context.Cause(tracked), ErrTaskCanceled)
+		cancel(nil)
+		return canceled
+	}
+}
+
+// cancelRunning cancels context of worker processing task, which was canceled by message provided,
+// if task is reserved by this consumer
+func (rq *RedisQueue) cancelRunning(message string) {
+	taskID, consumerID, ok := strings.Cut(strings.TrimPrefix(message, cancelMessagePrefix), ":")
+	if !ok || consumerID != rq.id {
+		return
+	}
+	rq.runningMu.Lock()
+	defer rq.runningMu.Unlock()
+	cancel, running := rq.running[taskID]
+	if running {
+		cancel(ErrTaskCanceled)
+		return
+	}
+	rq.canceled[taskID] = time.Now()
+}
+
+// forgetCanceled removes tasks, that were canceled long ago, but never reached worker, from memory.
+// Task, which mark is forgotten, is not processed anyway, since it is removed from in-flight list.
+func (rq *RedisQueue) forgetCanceled() {
+	rq.runningMu.Lock()
+	defer rq.runningMu.Unlock()
+	for taskID, at := range rq.canceled {
+		if time.Since(at) > PresenceTimeout {
+			delete(rq.canceled, taskID)
+		}
+	}
+}
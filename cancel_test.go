@@ -0,0 +1,232 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_CancelPending(t *testing.T) {
+	rq, err := New(t.Context(), "testCancelPending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	kept, err := rq.Publish(t.Context(), "kept")
+	if err != nil {
+		t.Error(err)
+	}
+	pending, err := rq.PublishWithPriority(t.Context(), PriorityHigh, "canceled")
+	if err != nil {
+		t.Error(err)
+	}
+	scheduled, err := rq.PublishIn(t.Context(), time.Hour, "canceled")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, id := range []string{pending, scheduled} {
+		result, errC := rq.Cancel(t.Context(), id)
+		if errC != nil {
+			t.Error(errC)
+		}
+		if result != CancelPending {
+			t.Errorf("task %s is %s instead of pending", id, result)
+		}
+	}
+	result, err := rq.Cancel(t.Context(), pending)
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelNotFound {
+		t.Errorf("task canceled twice is %s", result)
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number of tasks %v left in queue", n)
+	}
+	n, err = rq.CountScheduled(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("wrong number of scheduled tasks %v", n)
+	}
+	raw, found, err := rq.reserve(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found || decodeTask(raw).ID != kept {
+		t.Errorf("wrong task %s is left in queue", raw)
+	}
+	err = rq.ack(t.Context(), raw)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisQueue_CancelRunning(t *testing.T) {
+	publisher, err := New(t.Context(), "testCancelRunning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	consumer, err := New(t.Context(), "testCancelRunning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	consumer.SetHeartbeat(100 * time.Millisecond)
+
+	started := make(chan struct{})
+	var cause error
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+			close(started)
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			cancel()
+			return ctx.Err()
+		}, 1)
+	}()
+	id, err := publisher.Publish(t.Context(), "long running task")
+	if err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-started:
+	case <-cc.Done():
+		t.Fatalf("task is not started")
+	}
+	result, err := publisher.Cancel(t.Context(), id)
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelRunning {
+		t.Errorf("running task is %s", result)
+	}
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if !errors.Is(cause, ErrTaskCanceled) {
+		t.Errorf("worker context is canceled by %v", cause)
+	}
+	n, err := publisher.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("canceled task is returned to queue")
+	}
+	n, err = publisher.CountDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("canceled task is moved to dead letter queue")
+	}
+}
+
+func TestRedisQueue_CancelForgotten(t *testing.T) {
+	publisher, err := New(t.Context(), "testCancelForgotten")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	consumer, err := New(t.Context(), "testCancelForgotten")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	// blocking consumer holds next task, while its only worker is busy
+	consumer.SetBlocking(true)
+	consumer.SetHeartbeat(time.Hour)
+	consumer.SetResultTTL(time.Minute)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+			if payload != "busy" {
+				t.Errorf("canceled task %s is processed", payload)
+				return nil
+			}
+			close(started)
+			<-release
+			return nil
+		}, 0)
+	}()
+	_, err = publisher.Publish(t.Context(), "busy")
+	if err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-started:
+	case <-cc.Done():
+		t.Fatalf("task is not started")
+	}
+	id, err := publisher.Publish(t.Context(), "waiting for worker")
+	if err != nil {
+		t.Error(err)
+	}
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		n, errL := publisher.client.LLen(t.Context(), consumer.processingKey(consumer.GetID())).Result()
+		if errL != nil {
+			t.Error(errL)
+		}
+		if n == 2 {
+			break
+		}
+	}
+	result, err := publisher.Cancel(t.Context(), id)
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelRunning {
+		t.Errorf("reserved task is %s", result)
+	}
+	time.Sleep(100 * time.Millisecond)
+	// mark of cancel is forgotten, like it happens after PresenceTimeout
+	consumer.runningMu.Lock()
+	if _, found := consumer.canceled[id]; !found {
+		t.Errorf("cancel of reserved task is not received")
+	}
+	consumer.canceled[id] = time.Now().Add(-2 * PresenceTimeout)
+	consumer.runningMu.Unlock()
+	consumer.forgetCanceled()
+	close(release)
+
+	wc, wcancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer wcancel()
+	_, err = publisher.Wait(wc, id)
+	if !errors.Is(err, ErrTaskCanceled) {
+		t.Errorf("wrong result %v of canceled task", err)
+	}
+	cancel()
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,223 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HeaderChain is header of task, that is step of chain, which holds id of chain
+	HeaderChain = "grq-chain"
+	// HeaderChainStep is header of task, that is step of chain, which holds index of step
+	HeaderChainStep = "grq-chain-step"
+)
+
+// DefaultChainTTL is how long state of finished chain is kept in redis
+const DefaultChainTTL = 24 * time.Hour
+
+// ErrChainNotFound is returned by GetChain, when chain does not exist, or its state is expired
+var ErrChainNotFound = fmt.Errorf("chain is not found")
+
+// ChainStatus is state of chain
+type ChainStatus string
+
+const (
+	// ChainRunning means chain has steps to be processed
+	ChainRunning ChainStatus = "running"
+	// ChainSucceeded means all steps of chain are processed successfully
+	ChainSucceeded ChainStatus = "succeeded"
+	// ChainStopped means step of chain failed, expired or was canceled, so next steps are never published
+	ChainStopped ChainStatus = "stopped"
+)
+
+// ChainStep is task of chain, which is published to queue provided, when previous step succeeds
+type ChainStep struct {
+	// Queue is name of queue, where task of step is published
+	Queue string `json:"queue"`
+	// Payload is payload of task of step
+	Payload string `json:"payload"`
+	// TaskID is id of task of step, it is set, when step is published
+	TaskID string `json:"task_id,omitempty"`
+	// Status shows, how task of step is finished, it is empty, while step is not finished
+	Status ResultStatus `json:"status,omitempty"`
+}
+
+// Chain is state of chain of tasks stored in redis
+type Chain struct {
+	ID        string      `json:"id"`
+	Status    ChainStatus `json:"status"`
+	Current   int         `json:"current"`
+	Steps     []ChainStep `json:"steps"`
+	Error     string      `json:"error,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// chainKey returns name of key, where state of chain is stored
+func chainKey(chainID string) string {
+	return fmt.Sprintf("%schain/%s", ChannelPrefix, chainID)
+}
+
+// PublishChain publishes first step of chain, and every next step is published by consumer, which processed
+// previous step successfully. If step is moved to dead letter queue, expires or is canceled, chain is stopped.
+// Steps can be published to different queues of the same redis server, and their consumers should be
+// made by this package. Id of chain is returned, and state of chain can be received via GetChain.
+func (rq *RedisQueue) PublishChain(initialCtx context.Context, steps ...ChainStep) (chainID string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishChain",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("steps", len(steps)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if len(steps) == 0 {
+		return "", fmt.Errorf("chain has no steps")
+	}
+	for i := range steps {
+		if steps[i].Queue == "" {
+			return "", fmt.Errorf("step %v of chain has no queue", i)
+		}
+	}
+	chainID, err = getRandomID()
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("chain.id", chainID))
+	chain := Chain{
+		ID:        chainID,
+		Status:    ChainRunning,
+		Steps:     slices.Clone(steps),
+		UpdatedAt: time.Now(),
+	}
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return rq.startStep(ctx, pipe, &chain, 0)
+	})
+	return chainID, err
+}
+
+// GetChain returns state of chain with id provided
+func (rq *RedisQueue) GetChain(initialCtx context.Context, chainID string) (chain Chain, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.GetChain",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("chain.id", chainID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	data, err := rq.client.Get(ctx, chainKey(chainID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return chain, ErrChainNotFound
+		}
+		return
+	}
+	err = json.Unmarshal(data, &chain)
+	return
+}
+
+// startStep publishes step of chain with index provided and saves state of chain
+func (rq *RedisQueue) startStep(ctx context.Context, pipe redis.Pipeliner, chain *Chain, n int) (err error) {
+	step := &chain.Steps[n]
+	task, err := rq.newTask(step.Payload)
+	if err != nil {
+		return
+	}
+	task.Headers = map[string]string{
+		HeaderChain:     chain.ID,
+		HeaderChainStep: strconv.Itoa(n),
+	}
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	step.TaskID = task.ID
+	chain.Current = n
+	pipe.RPush(ctx, step.Queue, raw)
+	pipe.Publish(ctx, ChannelPrefix+step.Queue, "1")
+	return saveChain(ctx, pipe, chain)
+}
+
+// saveChain saves state of chain, and state of finished chain is kept for DefaultChainTTL
+func saveChain(ctx context.Context, pipe redis.Pipeliner, chain *Chain) (err error) {
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return
+	}
+	ttl := time.Duration(0)
+	if chain.Status != ChainRunning {
+		ttl = DefaultChainTTL
+	}
+	pipe.Set(ctx, chainKey(chain.ID), data, ttl)
+	return nil
+}
+
+// loadChain loads state of chain, which task belongs to. Nil is returned, if task is not step of chain,
+// or chain is expired.
+func (rq *RedisQueue) loadChain(ctx context.Context, c redis.Cmdable, task Task) (chain *Chain, err error) {
+	chainID := task.Headers[HeaderChain]
+	if chainID == "" {
+		return nil, nil
+	}
+	data, err := c.Get(ctx, chainKey(chainID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return
+	}
+	chain = &Chain{}
+	err = json.Unmarshal(data, chain)
+	if err != nil {
+		return nil, fmt.Errorf("%w : while parsing state of chain %s", err, chainID)
+	}
+	return
+}
+
+// recordChain publishes next step of chain, if task of current step succeeded, or stops chain, if it failed.
+// Repeated deliveries of steps, that are already finished, are ignored.
+func (rq *RedisQueue) recordChain(ctx context.Context, pipe redis.Pipeliner, chain *Chain, task Task, result TaskResult) (err error) {
+	n, err := strconv.Atoi(task.Headers[HeaderChainStep])
+	if err != nil || chain.Status != ChainRunning || n != chain.Current || chain.Steps[n].TaskID != task.ID {
+		return nil
+	}
+	chain.Steps[n].Status = result.Status
+	chain.UpdatedAt = result.FinishedAt
+	switch {
+	case result.Status != ResultSucceeded:
+		chain.Status = ChainStopped
+		chain.Error = fmt.Sprintf("step %v is %s", n, result.Status)
+		if result.Error != "" {
+			chain.Error += " : " + result.Error
+		}
+	case n+1 == len(chain.Steps):
+		chain.Status = ChainSucceeded
+	default:
+		return rq.startStep(ctx, pipe, chain, n+1)
+	}
+	return saveChain(ctx, pipe, chain)
+}
@@ -0,0 +1,124 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PublishChain(t *testing.T) {
+	queues := []string{"testChainA", "testChainB"}
+	publisher, err := New(t.Context(), queues[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	mu := sync.Mutex{}
+	var processed []string
+	cc, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for _, name := range queues {
+		rq, errC := New(t.Context(), name)
+		if errC != nil {
+			t.Fatal(errC)
+		}
+		defer rq.Close()
+		errC = rq.Purge(t.Context())
+		if errC != nil {
+			t.Error(errC)
+		}
+		rq.SetHeartbeat(100 * time.Millisecond)
+		rq.SetMaxAttempts(1)
+		wg.Go(func() {
+			errW := rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				mu.Lock()
+				processed = append(processed, payload)
+				mu.Unlock()
+				if payload == "fail" {
+					return fmt.Errorf("something is wrong")
+				}
+				return nil
+			}, 1)
+			if errW != nil && !errors.Is(errW, context.Canceled) {
+				t.Error(errW)
+			}
+		})
+	}
+
+	succeeded, err := publisher.PublishChain(t.Context(),
+		ChainStep{Queue: queues[0], Payload: "download"},
+		ChainStep{Queue: queues[1], Payload: "resize"},
+		ChainStep{Queue: queues[0], Payload: "upload"},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	chain := waitChain(t, publisher, succeeded)
+	if chain.Status != ChainSucceeded {
+		t.Errorf("wrong status %s of chain", chain.Status)
+	}
+	for i, step := range chain.Steps {
+		if step.Status != ResultSucceeded || step.TaskID == "" {
+			t.Errorf("wrong state %v of step %v", step, i)
+		}
+	}
+	mu.Lock()
+	if fmt.Sprint(processed) != "[download resize upload]" {
+		t.Errorf("wrong order of steps %v", processed)
+	}
+	processed = nil
+	mu.Unlock()
+
+	stopped, err := publisher.PublishChain(t.Context(),
+		ChainStep{Queue: queues[1], Payload: "fail"},
+		ChainStep{Queue: queues[0], Payload: "never"},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	chain = waitChain(t, publisher, stopped)
+	if chain.Status != ChainStopped || chain.Current != 0 {
+		t.Errorf("wrong state %v of failed chain", chain)
+	}
+	if chain.Steps[0].Status != ResultFailed || chain.Steps[1].TaskID != "" {
+		t.Errorf("wrong steps %v of failed chain", chain.Steps)
+	}
+	if chain.Error != "step 0 is failed : something is wrong" {
+		t.Errorf("wrong error %s of chain", chain.Error)
+	}
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	if fmt.Sprint(processed) != "[fail]" {
+		t.Errorf("steps %v are processed after failure", processed)
+	}
+	mu.Unlock()
+
+	_, err = publisher.GetChain(t.Context(), "unknown")
+	if !errors.Is(err, ErrChainNotFound) {
+		t.Errorf("wrong error %v for unknown chain", err)
+	}
+	cancel()
+	wg.Wait()
+}
+
+// waitChain waits until chain is finished
+func waitChain(t *testing.T, rq *RedisQueue, chainID string) (chain Chain) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		chain, err = rq.GetChain(t.Context(), chainID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if chain.Status != ChainRunning {
+			return
+		}
+	}
+	t.Errorf("chain %s is not finished", chainID)
+	return
+}
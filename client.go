@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -35,11 +37,41 @@ type RedisQueue struct {
 	timeout   time.Duration
 	id        string
 
+	maxAttempts int
+	maxPanics   int
+	retryPolicy RetryPolicy
+
+	priorityWeights []int
+	rateLimit       RateLimit
+	taskTTL         time.Duration
+	resultTTL       time.Duration
+	blocking        bool
+	expiryAction    ExpiryAction
+
+	globalConcurrency int
+	slotsMu           sync.Mutex
+	slots             []string
+
+	runningMu sync.Mutex
+	running   map[string]context.CancelCauseFunc
+	canceled  map[string]time.Time
+
+	callsMu   sync.Mutex
+	calls     map[string]chan TaskResult
+	receiving bool
+
 	client   *redis.Client
 	listener *redis.Client
 
 	isConsumerRunning bool
 	ticker            *time.Ticker
+	wake              chan struct{}
+	due               chan struct{}
+	dueMu             sync.Mutex
+	dueTimer          *time.Timer
+	dueAt             time.Time
+	paused            atomic.Bool
+	resumed           chan struct{}
 	subscriber        *redis.PubSub
 	startedAt         time.Time
 }
@@ -64,6 +96,16 @@ func (rq *RedisQueue) GetQueueName() string {
 	return rq.name
 }
 
+// key returns name of auxiliary redis key of kind provided, used by this queue
+func (rq *RedisQueue) key(kind string) string {
+	return fmt.Sprintf("%s%s_%s", ChannelPrefix, kind, rq.name)
+}
+
+// processingKey returns name of list, where consumer with id provided keeps tasks being processed
+func (rq *RedisQueue) processingKey(consumerID string) string {
+	return fmt.Sprintf("%s/%s", rq.key("processing"), consumerID)
+}
+
 // Close closes all connections to redis
 func (rq *RedisQueue) Close() (err error) {
 	return rq.client.Close()
@@ -98,6 +140,14 @@ func NewFromOptions(ctx context.Context, queue string, options redis.Options) (r
 		heartbeat: DefaultHeartbeat,
 		id:        fmt.Sprintf("%s/%s/%s/%v", hostname, queue, id, os.Getpid()),
 		timeout:   DefaultTaskTimeout,
+
+		maxAttempts: DefaultMaxAttempts,
+		maxPanics:   DefaultMaxPanics,
+
+		resumed:  make(chan struct{}, 1),
+		running:  make(map[string]context.CancelCauseFunc),
+		canceled: make(map[string]time.Time),
+		calls:    make(map[string]chan TaskResult),
 	}
 	r.client = redis.NewClient(r.options)
 	err = r.client.Ping(ctx).Err()
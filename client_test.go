@@ -92,15 +92,15 @@ func TestNew(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	err = rq.Publish(t.Context(), "something")
+	_, err = rq.Publish(t.Context(), "something")
 	if err != nil {
 		t.Error(err)
 	}
-	err = rq.Publish(t.Context(), time.Now())
+	_, err = rq.Publish(t.Context(), time.Now())
 	if err != nil {
 		t.Error(err)
 	}
-	err = rq.Publish(t.Context(), 1234)
+	_, err = rq.Publish(t.Context(), 1234)
 	if err != nil {
 		t.Error(err)
 	}
@@ -152,7 +152,7 @@ func TestNew(t *testing.T) {
 		t.Errorf("wrong number of tasks in queue")
 	}
 
-	err = rq.Publish(t.Context(), "nothing")
+	_, err = rq.Publish(t.Context(), "nothing")
 	if err != nil {
 		t.Error(err)
 	}
@@ -174,7 +174,7 @@ func TestNew(t *testing.T) {
 		t.Error(err)
 	}
 
-	err = rq.Publish(t.Context(), "it will fail")
+	_, err = rq.Publish(t.Context(), "it will fail")
 	if err != nil {
 		if err.Error() != "redis: client is closed" {
 			t.Error(err)
@@ -19,12 +19,14 @@ func (rq *RedisQueue) SetHeartbeat(interval time.Duration) {
 	rq.heartbeat = interval
 }
 
-// SetConsumerTimeout sets maximum for execution duration of task
+// SetConsumerTimeout sets maximum for execution duration of task. Worker can extend it for task being processed
+// via Lease returned by LeaseFromContext.
 func (rq *RedisQueue) SetConsumerTimeout(interval time.Duration) {
 	rq.timeout = interval
 }
 
-// GetTask consumes one task from channel
+// GetTask consumes one task of the highest priority level from channel. Task is removed from queue immediately,
+// so it is lost, if caller fails to process it. Expired tasks are skipped.
 func (rq *RedisQueue) GetTask(initialCtx context.Context) (payload string, found bool, err error) {
 	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.GetTask",
 		trace.WithSpanKind(trace.SpanKindConsumer),
@@ -32,17 +34,33 @@ func (rq *RedisQueue) GetTask(initialCtx context.Context) (payload string, found
 	)
 	attachCodeLocationToSpan(span)
 	defer span.End()
-	payload, err = rq.client.LPop(ctx, rq.name).Result()
-	if err != nil {
-		if err == redis.Nil {
-			span.AddEvent("nothing found")
-			span.SetAttributes(attribute.Bool("found", false))
-			return "", false, nil
+	var raw string
+	var task Task
+	for {
+		raw, err = rq.pop(ctx)
+		if err != nil {
+			if err == redis.Nil {
+				span.AddEvent("nothing found")
+				span.SetAttributes(attribute.Bool("found", false))
+				return "", false, nil
+			}
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+			return
+		}
+		task = decodeTask(raw)
+		if !rq.isExpired(task, time.Now()) {
+			break
+		}
+		span.AddEvent("expired task is skipped", trace.WithAttributes(attribute.String("task.id", task.ID)))
+		err = rq.expire(ctx, raw, task, false)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+			return
 		}
-		span.SetStatus(codes.Error, err.Error())
-		span.RecordError(err)
-		return
 	}
+	payload = task.Payload
 	if payload != "" {
 		span.AddEvent("task is found")
 		span.SetAttributes(attribute.Bool("found", true))
@@ -52,6 +70,18 @@ func (rq *RedisQueue) GetTask(initialCtx context.Context) (payload string, found
 	return
 }
 
+// pop removes first task of the highest non-empty priority level from queue
+func (rq *RedisQueue) pop(ctx context.Context) (raw string, err error) {
+	keys := rq.levelKeys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		raw, err = rq.client.LPop(ctx, keys[i]).Result()
+		if err != redis.Nil {
+			return
+		}
+	}
+	return
+}
+
 // Age returns how long ago consumer was started
 func (rq *RedisQueue) Age() (d time.Duration, err error) {
 	d = time.Since(rq.startedAt)
@@ -66,16 +96,9 @@ func (rq *RedisQueue) ListConsumers(initialCtx context.Context) (consumers map[s
 	)
 	attachCodeLocationToSpan(span)
 	defer span.End()
-	err = rq.client.ZRemRangeByScore(
-		ctx, fmt.Sprintf("%sconsumers_%s", ChannelPrefix, rq.name),
-		"-inf", fmt.Sprint(time.Now().Add(-11*time.Second).Unix()),
-	).Err()
-	if err != nil {
-		return
-	}
 	c, err := rq.client.ZRangeByScoreWithScores(
-		ctx, fmt.Sprintf("%sconsumers_%s", ChannelPrefix, rq.name),
-		&redis.ZRangeBy{Min: fmt.Sprint(time.Now().Add(-10 * time.Second).Unix()), Max: "+inf"},
+		ctx, rq.key("consumers"),
+		&redis.ZRangeBy{Min: fmt.Sprint(time.Now().Add(-PresenceTimeout).Unix()), Max: "+inf"},
 	).Result()
 	if err != nil {
 		return
@@ -89,13 +112,14 @@ func (rq *RedisQueue) ListConsumers(initialCtx context.Context) (consumers map[s
 }
 
 func (rq *RedisQueue) presence(ctx context.Context) (err error) {
-	return rq.listener.ZAdd(ctx, fmt.Sprintf("%sconsumers_%s", ChannelPrefix, rq.name),
+	return rq.listener.ZAdd(ctx, rq.key("consumers"),
 		redis.Z{Score: float64(time.Now().Unix()), Member: rq.id},
 	).Err()
 }
 
+// wrapWorker traces execution of worker and recovers its panic, so it is returned as PanicError
 func (rq *RedisQueue) wrapWorker(input WorkerFunc) WorkerFunc {
-	return func(initialCtx context.Context, payload string, indx int) error {
+	return func(initialCtx context.Context, payload string, indx int) (err error) {
 		ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.worker",
 			trace.WithSpanKind(trace.SpanKindConsumer),
 			trace.WithAttributes(
@@ -104,14 +128,32 @@ func (rq *RedisQueue) wrapWorker(input WorkerFunc) WorkerFunc {
 				attribute.Int("consumer.index", indx),
 				attribute.Int("consumer.payload_size", len(payload)),
 			))
+		task, found := TaskFromContext(ctx)
+		if found {
+			span.SetAttributes(
+				attribute.String("task.id", task.ID),
+				attribute.Int("task.attempt", task.Attempt),
+			)
+		}
 		attachCodeLocationToSpan(span)
 		defer span.End()
+		defer recoverWorker(span, &err)
 		return input(ctx, payload, indx)
 	}
 }
 
-// ConsumeConcurrently starts getting tasks from channel
+// ConsumeConcurrently starts getting tasks from channel.
+// Each task is moved to in-flight list of this consumer, and it is removed from there only after worker
+// returned nil, so tasks are delivered at least once. Tasks, which worker failed to process, are returned to queue.
+// Next task is reserved only after worker reported, that it is idle, so tasks are left in queue for other consumers,
+// while all workers are busy, and consumer takes next task right after worker finished previous one.
+// Idle consumer takes tasks, when it is notified about them, and every heartbeat, or instantly, if blocking mode
+// is enabled by SetBlocking.
 func (rq *RedisQueue) ConsumeConcurrently(initialCtx context.Context, worker WorkerFunc, concurrency int) (err error) {
+	err = rq.checkBlocking()
+	if err != nil {
+		return
+	}
 	rq.listener = redis.NewClient(rq.options)
 	err = rq.listener.Ping(initialCtx).Err()
 	if err != nil {
@@ -121,99 +163,216 @@ func (rq *RedisQueue) ConsumeConcurrently(initialCtx context.Context, worker Wor
 	if err != nil {
 		return
 	}
-	feed := make(chan string, 1000)
+	err = rq.syncPaused(initialCtx)
+	if err != nil {
+		return
+	}
+	// timer is armed to the earliest scheduled task
+	rq.due = make(chan struct{}, 1)
+	_, err = rq.promote(initialCtx)
+	if err != nil {
+		return
+	}
+	feed := make(chan string)
+	idle := make(chan struct{})
+	// notify stays nil, unless blocking mode is enabled
+	var notify chan struct{}
+	if rq.blocking {
+		notify = make(chan struct{}, 1)
+	}
 	p := fmt.Sprintf("%s%s", ChannelPrefix, rq.name)
 	rq.subscriber = rq.listener.Subscribe(initialCtx, p)
 	rq.ticker = time.NewTicker(rq.heartbeat)
+	presenceTicker := time.NewTicker(PresenceTimeout / 3)
+	defer presenceTicker.Stop()
 	sb := rq.subscriber.Channel()
+	rq.wake = make(chan struct{}, 1)
 	rq.startedAt = time.Now()
 	rq.isConsumerRunning = true
 
 	eg, ctx := errgroup.WithContext(initialCtx)
 
 	eg.Go(func() error {
+		// stop performs cleanup, which should be performed even if consumer context is canceled
+		stop := func() error {
+			// log.Println("Consumer is stopping")
+			ctx2, cancel := context.WithTimeout(context.WithoutCancel(ctx), rq.timeout)
+			defer cancel()
+			rq.isConsumerRunning = false
+			rq.ticker.Stop()
+			errU := rq.subscriber.Unsubscribe(ctx2, p)
+			if errU != nil {
+				return errU
+			}
+			return rq.subscriber.Close()
+		}
+		// ready is true, when worker reported, that it is idle, and waits for task
+		ready := false
 		for {
-			select {
-
-			case <-ctx.Done():
-				// log.Println("Consumer is stopping")
+			if ready {
 				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
-				rq.isConsumerRunning = false
-				rq.ticker.Stop()
-				err = rq.listener.ZRem(ctx2, fmt.Sprintf("%sconsumers_%s", ChannelPrefix, rq.name), rq.id).Err()
-				if err != nil {
-					cancel()
-					return err
-				}
-				rq.ticker.Stop()
-				err = rq.subscriber.Unsubscribe(ctx2, p)
-				if err != nil {
-					cancel()
-					return err
+				payload, found, errR := rq.reserve(ctx2)
+				cancel()
+				if errR != nil {
+					return errR
 				}
-				err = rq.subscriber.Close()
-				if err != nil {
-					cancel()
-					return err
+				if found {
+					select {
+					case <-ctx.Done():
+						// reserved task is returned to queue, when consumer leaves
+						return stop()
+					case feed <- payload:
+					}
+					ready = false
+					continue
 				}
-				cancel()
-				return nil
+			}
+			// idle workers are not awaited, while one of them waits for task, and in blocking mode,
+			// when workers receive tasks from blocking call
+			var idleC chan struct{}
+			if !ready && !rq.blocking {
+				idleC = idle
+			}
+			// notifications, control messages and presence are handled, while all workers are busy
+			select {
+
+			case <-ctx.Done():
+				return stop()
+
+			case <-idleC:
+				ready = true
 
-			case <-sb:
+			case msg := <-sb:
 				// log.Println("Task event received")
-				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
-				payload, found, errGt := rq.GetTask(ctx2)
-				if errGt != nil {
-					cancel()
-					return err
+				if !rq.control(msg.Payload) && notify != nil {
+					// blocking call is interrupted to reserve task of any priority level
+					select {
+					case notify <- struct{}{}:
+					default:
+					}
 				}
-				if found {
-					feed <- payload
+
+			case <-rq.wake:
+				// rate limiter allows to take next task, or queue is resumed
+
+			case <-rq.due:
+				// the earliest scheduled task is due, and consumers are notified, when it is moved to queue
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				_, errP := rq.promote(ctx2)
+				cancel()
+				if errP != nil {
+					return errP
 				}
+
+			case <-presenceTicker.C:
+				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
+				errH := rq.housekeep(ctx2)
 				cancel()
+				if errH != nil {
+					return errH
+				}
 
 			case <-rq.ticker.C:
 				// log.Println("Task ticker is fired")
 				ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
-				err = rq.presence(ctx2)
-				if err != nil {
-					cancel()
-					return err
-				}
-				payload, found, errGt := rq.GetTask(ctx2)
-				if errGt != nil {
-					cancel()
-					return errGt
-				}
-				if found {
-					feed <- payload
-				}
+				_, errP := rq.promote(ctx2)
 				cancel()
+				if errP != nil {
+					return errP
+				}
 			}
 		}
 	})
 
+	// handoff stays nil, unless blocking mode is enabled
+	var handoff chan string
+	if rq.blocking {
+		handoff = make(chan string)
+		eg.Go(func() error {
+			return rq.block(ctx, handoff, notify)
+		})
+	}
+
 	for i := 0; i <= concurrency; i++ {
 		eg.Go(func() error {
 			for {
+				if handoff == nil {
+					// worker reports, that it is idle, so next task is reserved only for it
+					select {
+					case <-ctx.Done():
+						return nil
+					case idle <- struct{}{}:
+					}
+				}
 				select {
 				case <-ctx.Done():
 					return nil
 				case msg := <-feed:
-					ctx2, cancel := context.WithTimeout(ctx, rq.timeout)
-					errW := rq.wrapWorker(worker)(ctx2, msg, i)
+					errW := rq.process(ctx, worker, msg, i)
+					if errW != nil {
+						return errW
+					}
+				case msg := <-handoff:
+					errW := rq.process(ctx, worker, msg, i)
 					if errW != nil {
-						errW = rq.Publish(ctx, msg)
-						if errW != nil {
-							cancel()
-							return errW
-						}
+						return errW
 					}
-					cancel()
 				}
 			}
 		})
 	}
 
-	return eg.Wait()
+	err = eg.Wait()
+	ctx3, cancel := context.WithTimeout(context.WithoutCancel(initialCtx), rq.timeout)
+	defer cancel()
+	errL := rq.leave(ctx3)
+	if errL != nil && err == nil {
+		err = errL
+	}
+	return err
+}
+
+// housekeep reports presence of consumer, loads paused state of queue, prolongs slots of global concurrency it holds,
+// reaps dead consumers and moves scheduled tasks, that are due, to queue
+func (rq *RedisQueue) housekeep(ctx context.Context) (err error) {
+	err = rq.presence(ctx)
+	if err != nil {
+		return
+	}
+	err = rq.syncPaused(ctx)
+	if err != nil {
+		return
+	}
+	rq.forgetCanceled()
+	err = rq.refreshSlots(ctx)
+	if err != nil {
+		return
+	}
+	_, err = rq.Reap(ctx)
+	if err != nil {
+		return
+	}
+	_, err = rq.promote(ctx)
+	return
+}
+
+// leave returns tasks, that were reserved by stopping consumer, but not processed, back to queue,
+// releases their slots of global concurrency and removes consumer from list of consumers
+func (rq *RedisQueue) leave(ctx context.Context) (err error) {
+	_, err = rq.restore(ctx, rq.id)
+	if err != nil {
+		// consumer is left in list of consumers, so its tasks will be returned to queue by reaper
+		return
+	}
+	err = rq.releaseAllSlots(ctx)
+	if err != nil {
+		return
+	}
+	// consumer leaves list of consumers only when its in-flight list is empty,
+	// so reaper will not touch it while consumer is stopping
+	err = rq.listener.ZRem(ctx, rq.key("consumers"), rq.id).Err()
+	if err != nil {
+		return
+	}
+	return rq.listener.ZRem(ctx, rq.key("leases"), rq.id).Err()
 }
@@ -45,13 +45,13 @@ func TestRedisQueue_Heartbeat(tt *testing.T) {
 		}
 		t.Logf("Offline publisher %s started...", rq5.GetID())
 		for i := 0; i < testSendLimit; i++ {
-			err = rq5.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
+			_, err = rq5.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
 			if err != nil {
 				t.Error(err)
 			}
 			t.Logf("Task %v published", i)
 		}
-		err = rq5.PublishFirst(t.Context(), "this task will be executed as first one")
+		_, err = rq5.PublishFirst(t.Context(), "this task will be executed as first one")
 		if err != nil {
 			t.Errorf("%s : while publishing 1st task", err)
 		}
@@ -116,7 +116,7 @@ func TestRedisQueue_Heartbeat(tt *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		err = rq6.PublishFirst(t.Context(), "it will be rejected")
+		_, err = rq6.PublishFirst(t.Context(), "it will be rejected")
 		if err != nil {
 			if err.Error() != "redis: client is closed" {
 				t.Errorf("%s : while publishing first task to be rejected because of closed channel", err)
@@ -40,7 +40,7 @@ func TestRedisQueue_Parallel(tt *testing.T) {
 		}
 		for i := 0; i <= testSendLimit; i++ {
 			time.Sleep(100 * time.Millisecond)
-			err = rq1.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
+			_, err = rq1.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
 			if err != nil {
 				t.Error(err)
 			}
@@ -41,7 +41,7 @@ func TestRedisQueue_Retry(tt *testing.T) {
 		}
 		for i := 0; i <= testSendLimit; i++ {
 			time.Sleep(100 * time.Millisecond)
-			err = rq10.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
+			_, err = rq10.Publish(t.Context(), fmt.Sprintf("task %v created on %s", i, time.Now().Format(time.Stamp)))
 			if err != nil {
 				t.Error(err)
 			}
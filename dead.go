@@ -0,0 +1,227 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxAttempts means number of attempts to process task is not limited
+const DefaultMaxAttempts = 0
+
+// DeadTask is task, that consumers failed to process in maximum number of attempts allowed,
+// or that is quarantined, because worker panicked while processing it too many times.
+// Attempt of embedded Task is number of attempts made, and LastError is error returned by worker during last one.
+type DeadTask struct {
+	Task
+	// DiedAt is time when task was moved to dead letter queue
+	DiedAt time.Time `json:"died_at"`
+	// Stack is stack trace of worker, if it panicked during last attempt
+	Stack string `json:"stack,omitempty"`
+}
+
+// buryScript moves task from in-flight list to dead letter queue, if it is still in in-flight list
+var buryScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[2], ARGV[2])
+return 1
+`)
+
+// resurrectScript moves first task from dead letter queue back to queue, if it was not changed
+// by somebody else, and notifies consumers about it
+var resurrectScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) ~= ARGV[1] then
+	return 0
+end
+redis.call('LPOP', KEYS[1])
+redis.call('RPUSH', KEYS[2], ARGV[2])
+redis.call('PUBLISH', ARGV[3], '1')
+return 1
+`)
+
+// SetMaxAttempts sets maximum number of attempts to process task. When worker fails to process task
+// this number of times, task is moved to dead letter queue. Zero means number of attempts is not limited.
+func (rq *RedisQueue) SetMaxAttempts(n int) {
+	rq.maxAttempts = n
+}
+
+// fail registers failed attempt to process task and returns task back to queue, immediately or after delay
+// computed by retry policy, or moves it to dead letter queue, if task has run out of attempts or panics
+func (rq *RedisQueue) fail(ctx context.Context, raw string, task Task, reason error) (err error) {
+	now := time.Now()
+	if task.ID == "" {
+		// raw task pushed by other client is upgraded to envelope, so its attempts can be counted
+		task.ID, err = getRandomID()
+		if err != nil {
+			return
+		}
+		task.EnqueuedAt = now
+	}
+	if task.FirstFailedAt.IsZero() {
+		task.FirstFailedAt = now
+	}
+	task.LastError = reason.Error()
+	if task.Headers[HeaderReplyTo] != "" {
+		// caller is waiting for reply, so failed call is not retried, and error is replied instead
+		return rq.settle(ctx, raw, task, TaskResult{
+			TaskID:     task.ID,
+			Status:     ResultFailed,
+			Error:      task.LastError,
+			FinishedAt: now,
+		})
+	}
+	var stack string
+	var pe *PanicError
+	if errors.As(reason, &pe) {
+		task.Panics++
+		stack = pe.Stack
+	}
+	quarantined := rq.maxPanics > 0 && task.Panics >= rq.maxPanics
+	if !quarantined && (rq.maxAttempts == 0 || task.Attempt < rq.maxAttempts) {
+		delay := time.Duration(0)
+		if rq.retryPolicy != nil {
+			delay = rq.retryPolicy.Delay(task.Attempt)
+		}
+		task.Attempt++
+		if delay > 0 {
+			return rq.postpone(ctx, raw, task, delay)
+		}
+		return rq.reject(ctx, raw, task)
+	}
+	record, err := json.Marshal(DeadTask{Task: task, DiedAt: now, Stack: stack})
+	if err != nil {
+		return
+	}
+	err = buryScript.Run(ctx, rq.client,
+		[]string{rq.processingKey(rq.id), rq.key("dead")},
+		raw, record,
+	).Err()
+	if err != nil {
+		return
+	}
+	return rq.finish(ctx, task, ResultFailed, task.LastError)
+}
+
+// ListDead lists tasks from dead letter queue of this queue, starting from offset
+func (rq *RedisQueue) ListDead(initialCtx context.Context, offset, limit int64) (tasks []DeadTask, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.ListDead",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	records, err := rq.client.LRange(ctx, rq.key("dead"), offset, offset+limit-1).Result()
+	if err != nil {
+		return
+	}
+	tasks = make([]DeadTask, len(records))
+	for i := range records {
+		err = json.Unmarshal([]byte(records[i]), &tasks[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// CountDead counts tasks in dead letter queue of this queue
+func (rq *RedisQueue) CountDead(initialCtx context.Context) (n int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.CountDead",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	n, err = rq.client.LLen(ctx, rq.key("dead")).Result()
+	return
+}
+
+// RequeueDead moves all tasks from dead letter queue back to queue, so they can be processed again
+// with the same number of attempts allowed
+func (rq *RedisQueue) RequeueDead(initialCtx context.Context) (n int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.RequeueDead",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	var record, raw string
+	var moved int64
+	for {
+		record, err = rq.client.LIndex(ctx, rq.key("dead"), 0).Result()
+		if err != nil {
+			if err == redis.Nil {
+				err = nil
+			}
+			break
+		}
+		var dead DeadTask
+		err = json.Unmarshal([]byte(record), &dead)
+		if err != nil {
+			break
+		}
+		dead.Attempt = 1
+		dead.FirstFailedAt = time.Time{}
+		dead.LastError = ""
+		dead.Panics = 0
+		raw, err = dead.Task.encode()
+		if err != nil {
+			break
+		}
+		moved, err = resurrectScript.Run(ctx, rq.client,
+			[]string{rq.key("dead"), rq.levelKey(dead.Priority)},
+			record, raw, ChannelPrefix+rq.name,
+		).Int64()
+		if err != nil {
+			break
+		}
+		n += moved
+	}
+	span.SetAttributes(attribute.Int64("requeued", n))
+	return
+}
+
+// PurgeDead discards all tasks in dead letter queue of this queue
+func (rq *RedisQueue) PurgeDead(initialCtx context.Context) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PurgeDead",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	err = rq.client.Del(ctx, rq.key("dead")).Err()
+	return
+}
@@ -0,0 +1,135 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_DeadLetterQueue(tt *testing.T) {
+	const testDeadQueueName = "testDead"
+	const maxAttempts = 3
+
+	tt.Run("prepare", func(t *testing.T) {
+		rq, err := New(t.Context(), testDeadQueueName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rq.Close()
+		err = rq.Purge(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		err = rq.PurgeDead(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = rq.Publish(t.Context(), "poison message")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	tt.Run("consume", func(t *testing.T) {
+		rq, err := New(t.Context(), testDeadQueueName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rq.Close()
+		rq.SetHeartbeat(10 * time.Millisecond)
+		rq.SetMaxAttempts(maxAttempts)
+		cc, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var attempts int
+		err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+			attempts++
+			if attempts == maxAttempts {
+				// give consumer some time to bury task
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+			return fmt.Errorf("attempt %v failed", attempts)
+		}, 0)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+		if attempts != maxAttempts {
+			t.Errorf("wrong number of attempts %v", attempts)
+		}
+	})
+
+	tt.Run("inspect", func(t *testing.T) {
+		rq, err := New(t.Context(), testDeadQueueName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rq.Close()
+		n, err := rq.Count(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 0 {
+			t.Errorf("dead task is still in queue")
+		}
+		n, err = rq.CountDead(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 1 {
+			t.Errorf("wrong number of dead tasks %v", n)
+		}
+		dead, err := rq.ListDead(t.Context(), 0, 10)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(dead) != 1 {
+			t.Fatalf("wrong number of dead tasks listed %v", len(dead))
+		}
+		if dead[0].Payload != "poison message" {
+			t.Errorf("wrong payload of dead task %s", dead[0].Payload)
+		}
+		if dead[0].Attempt != maxAttempts {
+			t.Errorf("wrong number of attempts %v", dead[0].Attempt)
+		}
+		if dead[0].LastError != "attempt 3 failed" {
+			t.Errorf("wrong error %s", dead[0].LastError)
+		}
+		if dead[0].DiedAt.Before(dead[0].FirstFailedAt) {
+			t.Errorf("task died before it failed first time")
+		}
+	})
+
+	tt.Run("requeue", func(t *testing.T) {
+		rq, err := New(t.Context(), testDeadQueueName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rq.Close()
+		n, err := rq.RequeueDead(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 1 {
+			t.Errorf("wrong number of requeued tasks %v", n)
+		}
+		payload, found, err := rq.GetTask(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if !found || payload != "poison message" {
+			t.Errorf("wrong requeued task %s", payload)
+		}
+		n, err = rq.CountDead(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if n != 0 {
+			t.Errorf("dead letter queue is not empty")
+		}
+		err = rq.PurgeDead(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
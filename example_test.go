@@ -70,15 +70,15 @@ func Example() {
 	}()
 
 	// we send tasks via publisher, anything that can be stringified by fmt.Sprint will do the trick
-	err = publisher.Publish(context.TODO(), "message 1")
+	_, err = publisher.Publish(context.TODO(), "message 1")
 	if err != nil {
 		log.Fatalf("%s : while publishing message 1", err)
 	}
-	err = publisher.Publish(context.TODO(), time.Now())
+	_, err = publisher.Publish(context.TODO(), time.Now())
 	if err != nil {
 		log.Fatalf("%s : while publishing message 2", err)
 	}
-	err = publisher.Publish(context.TODO(), fmt.Errorf("errors can be stringified, so it will do the trick"))
+	_, err = publisher.Publish(context.TODO(), fmt.Errorf("errors can be stringified, so it will do the trick"))
 	if err != nil {
 		log.Fatalf("%s : while publishing message 3", err)
 	}
@@ -88,7 +88,7 @@ func Example() {
 	consumerCancel()
 
 	// this message will be saved in queue, but not consumed
-	err = publisher.Publish(context.TODO(), 10)
+	_, err = publisher.Publish(context.TODO(), 10)
 	if err != nil {
 		log.Fatalf("%s : while publishing message 3", err)
 	}
@@ -131,15 +131,15 @@ func ExampleRedisQueue_Publish() {
 		log.Fatalf("%s : while making publisher", err)
 	}
 	// we send tasks via publisher, anything that can be stringified by fmt.Sprint will do the trick
-	err = publisher.Publish(context.TODO(), "message 1")
+	_, err = publisher.Publish(context.TODO(), "message 1")
 	if err != nil {
 		log.Fatalf("%s : while publishing message 1", err)
 	}
-	err = publisher.Publish(context.TODO(), time.Now())
+	_, err = publisher.Publish(context.TODO(), time.Now())
 	if err != nil {
 		log.Fatalf("%s : while publishing message 2", err)
 	}
-	err = publisher.Publish(context.TODO(), fmt.Errorf("errors can be stringified, so it will do the trick"))
+	_, err = publisher.Publish(context.TODO(), fmt.Errorf("errors can be stringified, so it will do the trick"))
 	if err != nil {
 		log.Fatalf("%s : while publishing message 3", err)
 	}
@@ -50,15 +50,15 @@ func main() {
 	}()
 
 	// we send tasks via publisher, anything that can be stringified by fmt.Sprint will do the trick
-	err = publisher.Publish(mainCtx, "message 1")
+	_, err = publisher.Publish(mainCtx, "message 1")
 	if err != nil {
 		log.Fatalf("%s : while publishing message 1", err)
 	}
-	err = publisher.Publish(mainCtx, time.Now())
+	_, err = publisher.Publish(mainCtx, time.Now())
 	if err != nil {
 		log.Fatalf("%s : while publishing message 2", err)
 	}
-	err = publisher.Publish(mainCtx, fmt.Errorf("errors can be stringified, so it will do the trick"))
+	_, err = publisher.Publish(mainCtx, fmt.Errorf("errors can be stringified, so it will do the trick"))
 	if err != nil {
 		log.Fatalf("%s : while publishing message 3", err)
 	}
@@ -68,7 +68,7 @@ func main() {
 	cCancel()
 
 	// this message will be saved in queue, but not consumed
-	err = publisher.Publish(mainCtx, 10)
+	_, err = publisher.Publish(mainCtx, 10)
 	if err != nil {
 		log.Fatalf("%s : while publishing message 3", err)
 	}
@@ -24,7 +24,7 @@ func main() {
 	}
 
 	for t := range time.NewTicker(time.Second).C {
-		err = q.Publish(ctx, task{Payload: t.Format(time.Stamp)})
+		_, err = q.Publish(ctx, task{Payload: t.Format(time.Stamp)})
 		if err != nil {
 			log.Fatalf("%s : while publishing task", err)
 		}
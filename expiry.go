@@ -0,0 +1,104 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrTaskExpired is recorded as LastError of expired tasks moved to dead letter queue
+var ErrTaskExpired = fmt.Errorf("task expired")
+
+// ExpiryAction is what consumer does with expired task
+type ExpiryAction int
+
+const (
+	// ExpiryDiscard drops expired tasks
+	ExpiryDiscard ExpiryAction = iota
+	// ExpiryDeadLetter moves expired tasks to dead letter queue
+	ExpiryDeadLetter
+)
+
+// expireScript removes expired task from in-flight list, if ARGV[3] is set, moves it to dead letter queue,
+// if ARGV[2] is not empty, and increments counter of expired tasks
+var expireScript = redis.NewScript(`
+if ARGV[3] == '1' and redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[2] ~= '' then
+	redis.call('RPUSH', KEYS[2], ARGV[2])
+end
+redis.call('INCR', KEYS[3])
+return 1
+`)
+
+// SetTaskTTL sets maximum duration tasks can wait in queue since they were published, and action performed
+// on tasks found expired, when consumer takes them. Tasks can have their own expiration time set by
+// Task.ExpiresAt too. Zero ttl means only tasks with their own expiration time expire. Raw tasks
+// pushed by other clients have no time of publishing, so they expire only when they are upgraded to envelope
+// after failed attempt.
+func (rq *RedisQueue) SetTaskTTL(ttl time.Duration, action ExpiryAction) {
+	rq.taskTTL = ttl
+	rq.expiryAction = action
+}
+
+// isExpired checks, if task is expired at moment provided
+func (rq *RedisQueue) isExpired(task Task, now time.Time) bool {
+	if !task.ExpiresAt.IsZero() && now.After(task.ExpiresAt) {
+		return true
+	}
+	return rq.taskTTL > 0 && !task.EnqueuedAt.IsZero() && now.Sub(task.EnqueuedAt) > rq.taskTTL
+}
+
+// expire drops expired task or moves it to dead letter queue, and counts it.
+// If inFlight is true, task is removed from in-flight list of this consumer.
+func (rq *RedisQueue) expire(ctx context.Context, raw string, task Task, inFlight bool) (err error) {
+	var record []byte
+	if rq.expiryAction == ExpiryDeadLetter {
+		task.LastError = ErrTaskExpired.Error()
+		record, err = json.Marshal(DeadTask{Task: task, DiedAt: time.Now()})
+		if err != nil {
+			return
+		}
+	}
+	fromInFlight := "0"
+	if inFlight {
+		fromInFlight = "1"
+	}
+	err = expireScript.Run(ctx, rq.client,
+		[]string{rq.processingKey(rq.id), rq.key("dead"), rq.key("expired")},
+		raw, string(record), fromInFlight,
+	).Err()
+	if err != nil {
+		return
+	}
+	return rq.finish(ctx, task, ResultExpired, "")
+}
+
+// CountExpired returns number of tasks of this queue, that consumers found expired, since queue was created
+func (rq *RedisQueue) CountExpired(initialCtx context.Context) (n int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.CountExpired",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	n, err = rq.client.Get(ctx, rq.key("expired")).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_GetTaskSkipsExpired(t *testing.T) {
+	rq, err := New(t.Context(), "testExpiryGetTask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.key("expired")).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishTask(t.Context(), Task{Payload: "old otp", ExpiresAt: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishTask(t.Context(), Task{Payload: "new otp", ExpiresAt: time.Now().Add(2 * time.Minute)})
+	if err != nil {
+		t.Error(err)
+	}
+	payload, found, err := rq.GetTask(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found || payload != "new otp" {
+		t.Errorf("wrong task %s received", payload)
+	}
+	n, err := rq.CountExpired(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number of expired tasks %v", n)
+	}
+}
+
+func TestRedisQueue_SetTaskTTL(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+	rq, err := New(t.Context(), "testExpiryConsume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.PurgeDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.key("expired")).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.Publish(t.Context(), "stale")
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(2 * ttl)
+	_, err = rq.Publish(t.Context(), "fresh")
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	rq.SetTaskTTL(ttl, ExpiryDeadLetter)
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		if payload != "fresh" {
+			t.Errorf("expired task %s is passed to worker", payload)
+		}
+		cancel()
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	n, err := rq.CountExpired(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number of expired tasks %v", n)
+	}
+	dead, err := rq.ListDead(t.Context(), 0, 10)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("wrong number of dead tasks %v", len(dead))
+	}
+	if dead[0].Payload != "stale" || dead[0].LastError != ErrTaskExpired.Error() {
+		t.Errorf("wrong dead task %v", dead[0])
+	}
+	err = rq.PurgeDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,312 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HeaderGroup is header of task, that is member of group, which holds id of group
+	HeaderGroup = "grq-group"
+	// HeaderGroupMember is header of task, that is member of group, which holds index of member
+	HeaderGroupMember = "grq-group-member"
+)
+
+// DefaultGroupTTL is how long state of finished group is kept in redis
+const DefaultGroupTTL = 24 * time.Hour
+
+// ErrGroupNotFound is returned by GetGroup, when group does not exist, or its state is expired
+var ErrGroupNotFound = fmt.Errorf("group is not found")
+
+// GroupMember is task of group, which is published to queue provided together with other members
+type GroupMember struct {
+	// Queue is name of queue, where task of member is published
+	Queue string `json:"queue"`
+	// Payload is payload of task of member
+	Payload string `json:"payload"`
+}
+
+// GroupCallback is task published, when all members of group are finished
+type GroupCallback struct {
+	// Queue is name of queue, where callback is published
+	Queue string
+	// Payload is payload of callback
+	Payload string
+	// CollectResults makes payload of callback GroupResults encoded as JSON, with results of members in their order
+	CollectResults bool
+}
+
+// GroupResults is payload of callback, which collects results of members of group
+type GroupResults struct {
+	Payload string       `json:"payload"`
+	Results []TaskResult `json:"results"`
+}
+
+// Group is state of group of tasks stored in redis
+type Group struct {
+	// ID is id of group returned by PublishGroup
+	ID string `json:"id"`
+	// Total is number of members of group
+	Total int `json:"total"`
+	// Finished is number of members, that are finished successfully or not
+	Finished int `json:"finished"`
+	// Failed is number of members, that are finished unsuccessfully
+	Failed int `json:"failed"`
+	// CallbackTaskID is id of callback task, it is empty, if group has no callback
+	CallbackTaskID string `json:"callback_task_id,omitempty"`
+}
+
+// Done returns true, if all members of group are finished
+func (g Group) Done() bool {
+	return g.Finished >= g.Total
+}
+
+// groupKey returns name of hash, where state of group is stored
+func groupKey(groupID string) string {
+	return fmt.Sprintf("%sgroup/%s", ChannelPrefix, groupID)
+}
+
+// groupResultsKey returns name of hash, where outcomes of finished members of group are stored
+func groupResultsKey(groupID string) string {
+	return groupKey(groupID) + "/results"
+}
+
+// finishMemberScript records outcome of member of group, if it is not recorded yet, and publishes callback,
+// when the last member is finished. Results of members are put into payload of callback, if requested.
+// List of callback queue is passed as KEYS[3] and its channel as ARGV[5] only for groups with callback.
+// It returns 0 for repeated outcome, 1, if group has unfinished members, and 2, if group is finished.
+var finishMemberScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('HSETNX', KEYS[2], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+local finished = redis.call('HINCRBY', KEYS[1], 'finished', 1)
+if ARGV[3] == '0' then
+	redis.call('HINCRBY', KEYS[1], 'failed', 1)
+end
+local total = tonumber(redis.call('HGET', KEYS[1], 'total'))
+if finished < total then
+	return 1
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+local raw = redis.call('HGET', KEYS[1], 'callback')
+if not raw or not KEYS[3] then
+	return 2
+end
+local collect = redis.call('HMGET', KEYS[1], 'collect_head', 'collect_payload', 'collect_tail')
+if collect[1] then
+	local results = {}
+	for i = 0, total - 1 do
+		results[#results + 1] = redis.call('HGET', KEYS[2], tostring(i)) or 'null'
+	end
+	local payload = '{"payload":' .. collect[2] .. ',"results":[' .. table.concat(results, ',') .. ']}'
+	raw = collect[1] .. cjson.encode(payload) .. collect[3]
+end
+redis.call('RPUSH', KEYS[3], raw)
+redis.call('PUBLISH', ARGV[5], '1')
+return 2
+`)
+
+// PublishGroup publishes members of group, that can be processed in parallel by consumers of their queues,
+// and callback, if provided, is published by consumer, which finished the last member. Members are counted
+// as finished, when they succeed, or when they are moved to dead letter queue, expire or are canceled.
+// Members can be published to different queues of the same redis server, and their consumers should be made
+// by this package. Callback task is not expired by task TTL
+// of its queue. Id of group and ids of members are returned.
+func (rq *RedisQueue) PublishGroup(initialCtx context.Context, members []GroupMember, callback *GroupCallback) (groupID string, taskIDs []string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishGroup",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("members", len(members)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if len(members) == 0 {
+		return "", nil, fmt.Errorf("group has no members")
+	}
+	for i := range members {
+		if members[i].Queue == "" {
+			return "", nil, fmt.Errorf("member %v of group has no queue", i)
+		}
+	}
+	if callback != nil && callback.Queue == "" {
+		return "", nil, fmt.Errorf("callback of group has no queue")
+	}
+	groupID, err = getRandomID()
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("group.id", groupID))
+	fields := []any{"total", len(members), "finished", 0, "failed", 0}
+	if callback != nil {
+		var task Task
+		task, err = rq.newTask(callback.Payload)
+		if err != nil {
+			return
+		}
+		// callback can be published long after group, so it is not expired by task TTL
+		task.EnqueuedAt = time.Time{}
+		var raw string
+		raw, err = task.encode()
+		if err != nil {
+			return
+		}
+		fields = append(fields, "callback", raw, "callback_id", task.ID, "callback_queue", callback.Queue)
+		if callback.CollectResults {
+			var collect []any
+			collect, err = collectFields(task)
+			if err != nil {
+				return
+			}
+			fields = append(fields, collect...)
+		}
+	}
+	raws := make([]string, len(members))
+	taskIDs = make([]string, len(members))
+	for i := range members {
+		var task Task
+		task, err = rq.newTask(members[i].Payload)
+		if err != nil {
+			return
+		}
+		task.Headers = map[string]string{
+			HeaderGroup:       groupID,
+			HeaderGroupMember: strconv.Itoa(i),
+		}
+		raws[i], err = task.encode()
+		if err != nil {
+			return
+		}
+		taskIDs[i] = task.ID
+	}
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, groupKey(groupID), fields...)
+		var queues []string
+		for i := range members {
+			pipe.RPush(ctx, members[i].Queue, raws[i])
+			if !slices.Contains(queues, members[i].Queue) {
+				queues = append(queues, members[i].Queue)
+			}
+		}
+		for _, queue := range queues {
+			pipe.Publish(ctx, ChannelPrefix+queue, "1")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return groupID, taskIDs, nil
+}
+
+// collectFields returns fields of group, which allow script to put results of members into payload of callback.
+// Envelope of callback is split around its payload, so order of its fields is preserved.
+func collectFields(task Task) (fields []any, err error) {
+	payload, err := json.Marshal(task.Payload)
+	if err != nil {
+		return
+	}
+	// id of task is used as placeholder of payload, since it cannot be escaped
+	task.Payload = task.ID
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	head, tail, found := strings.Cut(raw, fmt.Sprintf(`"payload":"%s"`, task.ID))
+	if !found {
+		return nil, fmt.Errorf("payload is not found in envelope of callback")
+	}
+	return []any{"collect_head", head + `"payload":`, "collect_payload", string(payload), "collect_tail", tail}, nil
+}
+
+// GetGroup returns state of group with id provided
+func (rq *RedisQueue) GetGroup(initialCtx context.Context, groupID string) (group Group, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.GetGroup",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("group.id", groupID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	fields, err := rq.client.HGetAll(ctx, groupKey(groupID)).Result()
+	if err != nil {
+		return
+	}
+	if len(fields) == 0 {
+		return group, ErrGroupNotFound
+	}
+	group.ID = groupID
+	group.CallbackTaskID = fields["callback_id"]
+	for name, value := range map[string]*int{"total": &group.Total, "finished": &group.Finished, "failed": &group.Failed} {
+		*value, err = strconv.Atoi(fields[name])
+		if err != nil {
+			return group, fmt.Errorf("%w : while parsing field %s of group %s", err, name, groupID)
+		}
+	}
+	return
+}
+
+// groupLink is group, which task is member of
+type groupLink struct {
+	id            string
+	callbackQueue string
+}
+
+// loadGroup loads queue of callback of group, which task is member of. Nil is returned, if task is not member
+// of group.
+func (rq *RedisQueue) loadGroup(ctx context.Context, c redis.Cmdable, task Task) (group *groupLink, err error) {
+	groupID := task.Headers[HeaderGroup]
+	if groupID == "" {
+		return nil, nil
+	}
+	callbackQueue, err := c.HGet(ctx, groupKey(groupID), "callback_queue").Result()
+	if err != nil && err != redis.Nil {
+		return
+	}
+	return &groupLink{id: groupID, callbackQueue: callbackQueue}, nil
+}
+
+// recordGroup records outcome of member of group
+func (rq *RedisQueue) recordGroup(ctx context.Context, pipe redis.Pipeliner, group *groupLink, task Task, data []byte, result TaskResult) {
+	succeeded := "0"
+	if result.Status == ResultSucceeded {
+		succeeded = "1"
+	}
+	keys := []string{groupKey(group.id), groupResultsKey(group.id)}
+	args := []any{task.Headers[HeaderGroupMember], data, succeeded, DefaultGroupTTL.Milliseconds()}
+	if group.callbackQueue != "" {
+		keys = append(keys, group.callbackQueue)
+		args = append(args, ChannelPrefix+group.callbackQueue)
+	}
+	finishMemberScript.Eval(ctx, pipe, keys, args...)
+}
@@ -0,0 +1,163 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PublishGroup(t *testing.T) {
+	const shards = 20
+	publisher, err := New(t.Context(), "testGroupShards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	merger, err := New(t.Context(), "testGroupMerge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer merger.Close()
+	err = merger.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	merger.SetHeartbeat(100 * time.Millisecond)
+
+	members := make([]GroupMember, shards)
+	for i := range members {
+		members[i] = GroupMember{Queue: "testGroupShards", Payload: strconv.Itoa(i)}
+	}
+	members[shards-1].Payload = "fail"
+	groupID, taskIDs, err := publisher.PublishGroup(t.Context(), members, &GroupCallback{
+		Queue:          "testGroupMerge",
+		Payload:        "report",
+		CollectResults: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taskIDs) != shards {
+		t.Errorf("wrong number of member ids %v", len(taskIDs))
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Go(func() {
+			rq, errC := New(t.Context(), "testGroupShards")
+			if errC != nil {
+				t.Error(errC)
+				return
+			}
+			defer rq.Close()
+			rq.SetHeartbeat(100 * time.Millisecond)
+			rq.SetMaxAttempts(1)
+			errC = rq.ConsumeConcurrently(cc, WithResult(func(ctx context.Context, payload string, indx int) (string, error) {
+				if payload == "fail" {
+					return "", fmt.Errorf("shard is broken")
+				}
+				n, errA := strconv.Atoi(payload)
+				return strconv.Itoa(n * 2), errA
+			}), 3)
+			if errC != nil && !errors.Is(errC, context.Canceled) {
+				t.Error(errC)
+			}
+		})
+	}
+
+	var merged GroupResults
+	var callbacks int
+	err = merger.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		callbacks++
+		cancel()
+		return json.Unmarshal([]byte(payload), &merged)
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	wg.Wait()
+	if callbacks != 1 {
+		t.Fatalf("callback is called %v times", callbacks)
+	}
+	if merged.Payload != "report" || len(merged.Results) != shards {
+		t.Fatalf("wrong payload of callback %v", merged)
+	}
+	for i, result := range merged.Results[:shards-1] {
+		if result.Status != ResultSucceeded || result.Value != strconv.Itoa(i*2) || result.TaskID != taskIDs[i] {
+			t.Errorf("wrong result %v of member %v", result, i)
+		}
+	}
+	if last := merged.Results[shards-1]; last.Status != ResultFailed || last.Error != "shard is broken" {
+		t.Errorf("wrong result %v of failed member", last)
+	}
+
+	group, err := publisher.GetGroup(t.Context(), groupID)
+	if err != nil {
+		t.Error(err)
+	}
+	if !group.Done() || group.Total != shards || group.Failed != 1 || group.CallbackTaskID == "" {
+		t.Errorf("wrong state of group %v", group)
+	}
+	_, err = publisher.GetGroup(t.Context(), "unknown")
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("wrong error %v for unknown group", err)
+	}
+}
+
+func TestRedisQueue_PublishGroupWithoutCallback(t *testing.T) {
+	rq, err := New(t.Context(), "testGroupNoCallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(100 * time.Millisecond)
+	groupID, _, err := rq.PublishGroup(t.Context(), []GroupMember{
+		{Queue: "testGroupNoCallback", Payload: "a"},
+		{Queue: "testGroupNoCallback", Payload: "b"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	processed := 0
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		processed++
+		if processed == 2 {
+			cancel()
+		}
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	group, err := rq.GetGroup(t.Context(), groupID)
+	if err != nil {
+		t.Error(err)
+	}
+	if !group.Done() || group.Failed != 0 || group.CallbackTaskID != "" {
+		t.Errorf("wrong state of group %v", group)
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("%v tasks are published by group without callback", n)
+	}
+}
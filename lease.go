@@ -0,0 +1,150 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrLeaseExpired is returned, when worker tries to extend lease of task after its deadline passed
+// or after worker returned
+var ErrLeaseExpired = fmt.Errorf("lease of task is expired")
+
+// Lease is permission of worker to process task until its deadline. Initial deadline is set
+// by SetConsumerTimeout, and long-running workers can extend it via Lease taken from context
+// by LeaseFromContext.
+type Lease struct {
+	rq       *RedisQueue
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	expired  bool
+	released bool
+}
+
+type leaseContextKey struct{}
+
+// leaseContext is context of worker, which deadline moves, when lease is extended
+type leaseContext struct {
+	context.Context
+	lease *Lease
+}
+
+// Deadline returns current deadline of lease
+func (c leaseContext) Deadline() (deadline time.Time, ok bool) {
+	return c.lease.Deadline(), true
+}
+
+// Err returns context.DeadlineExceeded, if lease is expired, like context.WithTimeout does
+func (c leaseContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && c.lease.isExpired() {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// Value returns lease itself for leaseContextKey
+func (c leaseContext) Value(key any) any {
+	if _, ok := key.(leaseContextKey); ok {
+		return c.lease
+	}
+	return c.Context.Value(key)
+}
+
+// newLease makes context of worker canceled after timeout, unless lease is extended
+func (rq *RedisQueue) newLease(ctx context.Context, timeout time.Duration) (lease *Lease, leaseCtx context.Context) {
+	cancelable, cancel := context.WithCancel(ctx)
+	lease = &Lease{
+		rq:       rq,
+		deadline: time.Now().Add(timeout),
+		cancel:   cancel,
+	}
+	lease.timer = time.AfterFunc(timeout, lease.expire)
+	return lease, leaseContext{Context: cancelable, lease: lease}
+}
+
+// expire cancels context of worker, when deadline is passed
+func (l *Lease) expire() {
+	l.mu.Lock()
+	if !l.released {
+		l.expired = true
+	}
+	l.mu.Unlock()
+	l.cancel()
+}
+
+// release stops lease, when worker returned
+func (l *Lease) release() {
+	l.mu.Lock()
+	l.released = true
+	l.timer.Stop()
+	l.mu.Unlock()
+	l.cancel()
+}
+
+func (l *Lease) isExpired() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expired
+}
+
+// Deadline returns time, when context of worker will be canceled, unless lease is extended
+func (l *Lease) Deadline() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.deadline
+}
+
+// Extend moves deadline of task being processed to duration provided from now, and records it in redis,
+// so reaper does not consider consumer dead until new deadline passes.
+// ErrLeaseExpired is returned, if deadline is already passed or worker returned.
+func (l *Lease) Extend(initialCtx context.Context, d time.Duration) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.ExtendLease",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", l.rq.name),
+			attribute.String("consumer.id", l.rq.id),
+			attribute.String("extension", d.String()),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if d <= 0 {
+		return fmt.Errorf("lease extension %s should be positive", d)
+	}
+	l.mu.Lock()
+	if l.expired || l.released || !l.timer.Stop() {
+		l.mu.Unlock()
+		return ErrLeaseExpired
+	}
+	l.deadline = time.Now().Add(d)
+	l.timer.Reset(d)
+	deadline := l.deadline
+	l.mu.Unlock()
+	span.SetAttributes(attribute.String("deadline", deadline.Format(time.RFC3339Nano)))
+	return l.rq.client.ZAddGT(ctx, l.rq.key("leases"), redis.Z{
+		Score:  float64(deadline.Unix() + 1),
+		Member: l.rq.id,
+	}).Err()
+}
+
+// LeaseFromContext returns lease of task being processed by WorkerFunc, which received context provided
+func LeaseFromContext(ctx context.Context) (lease *Lease, found bool) {
+	lease, found = ctx.Value(leaseContextKey{}).(*Lease)
+	return
+}
@@ -0,0 +1,144 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisQueue_ExtendLease(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	rq, err := New(t.Context(), "testExtendLease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	rq.SetConsumerTimeout(timeout)
+	_, err = rq.Publish(t.Context(), "long")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.Publish(t.Context(), "short")
+	if err != nil {
+		t.Error(err)
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var extended *Lease
+	var processed int
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		processed++
+		lease, found := LeaseFromContext(ctx)
+		if !found {
+			t.Errorf("lease is not found in context")
+			return nil
+		}
+		switch payload {
+		case "long":
+			extended = lease
+			errE := lease.Extend(ctx, 3*timeout)
+			if errE != nil {
+				t.Error(errE)
+			}
+			deadline, ok := ctx.Deadline()
+			if !ok || !deadline.Equal(lease.Deadline()) {
+				t.Errorf("wrong deadline of context %s instead of %s", deadline, lease.Deadline())
+			}
+			time.Sleep(2 * timeout)
+			if ctx.Err() != nil {
+				t.Errorf("context of extended lease is canceled: %s", ctx.Err())
+			}
+		case "short":
+			<-ctx.Done()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Errorf("wrong error %v of expired lease", ctx.Err())
+			}
+			errE := lease.Extend(context.WithoutCancel(ctx), timeout)
+			if !errors.Is(errE, ErrLeaseExpired) {
+				t.Errorf("expired lease is extended: %v", errE)
+			}
+			cancel()
+		}
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if processed != 2 {
+		t.Errorf("wrong number of processed tasks %v", processed)
+	}
+	if extended == nil {
+		t.Fatalf("long task is not processed")
+	}
+	err = extended.Extend(t.Context(), timeout)
+	if !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("lease is extended after worker returned: %v", err)
+	}
+}
+
+func TestRedisQueue_ReapRespectsLease(t *testing.T) {
+	rq, err := New(t.Context(), "testReapLease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	const busyConsumer = "busyPod/testReapLease/1"
+	err = rq.client.ZAdd(t.Context(), rq.key("consumers"),
+		redis.Z{Score: float64(time.Now().Add(-time.Minute).Unix()), Member: busyConsumer},
+	).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.ZAdd(t.Context(), rq.key("leases"),
+		redis.Z{Score: float64(time.Now().Add(time.Minute).Unix()), Member: busyConsumer},
+	).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.RPush(t.Context(), rq.processingKey(busyConsumer), "transcoding").Err()
+	if err != nil {
+		t.Error(err)
+	}
+	requeued, err := rq.Reap(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if requeued != 0 {
+		t.Errorf("tasks of consumer with extended lease are requeued")
+	}
+	// lease is expired
+	err = rq.client.ZAdd(t.Context(), rq.key("leases"),
+		redis.Z{Score: float64(time.Now().Add(-time.Second).Unix()), Member: busyConsumer},
+	).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	requeued, err = rq.Reap(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if requeued != 1 {
+		t.Errorf("wrong number of requeued tasks %v", requeued)
+	}
+	stale, err := rq.client.ZScore(t.Context(), rq.key("leases"), busyConsumer).Result()
+	if err != redis.Nil {
+		t.Errorf("lease of dead consumer is not removed, score %v, error %v", stale, err)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,297 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+)
+
+// Selection is how MultiConsumer chooses queue to take next task from
+type Selection int
+
+const (
+	// SelectStrict takes tasks from queues in order they were added to MultiConsumer,
+	// so queue is served only when all queues added before it are empty
+	SelectStrict Selection = iota
+	// SelectWeighted takes tasks from queues by smooth weighted round-robin,
+	// so every non-empty queue receives share of workers proportional to its weight
+	SelectWeighted
+)
+
+// multiQueue is queue served by MultiConsumer
+type multiQueue struct {
+	rq      *RedisQueue
+	worker  WorkerFunc
+	weight  int
+	current int
+}
+
+// delivery is task reserved by MultiConsumer for worker
+type delivery struct {
+	queue *multiQueue
+	raw   string
+}
+
+// MultiConsumer serves several queues by one shared pool of workers, using one connection
+// to receive notifications. Every queue has its own worker, and all queues should use the same redis server.
+type MultiConsumer struct {
+	selection Selection
+	heartbeat time.Duration
+	queues    []*multiQueue
+}
+
+// NewMultiConsumer creates consumer of several queues, which chooses queue to take next task from
+// by selection provided
+func NewMultiConsumer(selection Selection) *MultiConsumer {
+	return &MultiConsumer{
+		selection: selection,
+		heartbeat: DefaultHeartbeat,
+	}
+}
+
+// SetHeartbeat sets interval, after which MultiConsumer tries to consume tasks from its queues
+func (mc *MultiConsumer) SetHeartbeat(interval time.Duration) {
+	mc.heartbeat = interval
+}
+
+// Add adds queue with worker processing its tasks. Weight is used by SelectWeighted only, and weights
+// less than 1 are treated as 1. Timeouts, retry policies and other settings of queue are respected.
+func (mc *MultiConsumer) Add(rq *RedisQueue, weight int, worker WorkerFunc) (err error) {
+	for _, q := range mc.queues {
+		if q.rq.name == rq.name {
+			return fmt.Errorf("queue %s is already added", rq.name)
+		}
+	}
+	mc.queues = append(mc.queues, &multiQueue{
+		rq:     rq,
+		worker: worker,
+		weight: max(weight, 1),
+	})
+	return nil
+}
+
+// candidates returns queues in order they should be checked for tasks
+func (mc *MultiConsumer) candidates() []*multiQueue {
+	candidates := slices.Clone(mc.queues)
+	if mc.selection == SelectWeighted {
+		slices.SortStableFunc(candidates, func(a, b *multiQueue) int {
+			return (b.current + b.weight) - (a.current + a.weight)
+		})
+	}
+	return candidates
+}
+
+// next reserves task from queue chosen by selection
+func (mc *MultiConsumer) next(ctx context.Context) (d delivery, found bool, err error) {
+	for _, q := range mc.candidates() {
+		ctx2, cancel := context.WithTimeout(ctx, q.rq.timeout)
+		d.raw, found, err = q.rq.reserve(ctx2)
+		cancel()
+		if err != nil {
+			return
+		}
+		if !found {
+			// idle queue does not accumulate credit
+			q.current = 0
+			continue
+		}
+		if mc.selection == SelectWeighted {
+			total := 0
+			for _, other := range mc.queues {
+				other.current += other.weight
+				total += other.weight
+			}
+			q.current -= total
+		}
+		d.queue = q
+		return d, true, nil
+	}
+	return
+}
+
+// control applies control message to queue, whose channel it was received from
+func (mc *MultiConsumer) control(msg *redis.Message) {
+	for _, q := range mc.queues {
+		if msg.Channel == ChannelPrefix+q.rq.name {
+			q.rq.control(msg.Payload)
+			return
+		}
+	}
+}
+
+// housekeep reports presence of consumer on every queue, reaps dead consumers and promotes scheduled tasks
+func (mc *MultiConsumer) housekeep(ctx context.Context) (err error) {
+	for _, q := range mc.queues {
+		ctx2, cancel := context.WithTimeout(ctx, q.rq.timeout)
+		err = q.rq.housekeep(ctx2)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// promote moves scheduled tasks, that are due, to every queue
+func (mc *MultiConsumer) promote(ctx context.Context) (err error) {
+	for _, q := range mc.queues {
+		ctx2, cancel := context.WithTimeout(ctx, q.rq.timeout)
+		_, err = q.rq.promote(ctx2)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Consume starts getting tasks from all queues added, and processes them by concurrency+1 workers shared
+// by all queues. Tasks are delivered at least once, like ones consumed by ConsumeConcurrently.
+// Next task is reserved only after worker reported, that it is idle, so selection is respected even if queues
+// are long, and tasks are left in queues for other consumers, while all workers are busy.
+func (mc *MultiConsumer) Consume(initialCtx context.Context, concurrency int) (err error) {
+	if len(mc.queues) == 0 {
+		return fmt.Errorf("no queues are added to consumer")
+	}
+	listener := redis.NewClient(mc.queues[0].rq.options)
+	defer listener.Close()
+	err = listener.Ping(initialCtx).Err()
+	if err != nil {
+		return
+	}
+	channels := make([]string, len(mc.queues))
+	for i, q := range mc.queues {
+		channels[i] = ChannelPrefix + q.rq.name
+		q.rq.listener = listener
+		err = q.rq.presence(initialCtx)
+		if err != nil {
+			return
+		}
+		err = q.rq.syncPaused(initialCtx)
+		if err != nil {
+			return
+		}
+		q.rq.startedAt = time.Now()
+		q.rq.isConsumerRunning = true
+	}
+	due := make(chan struct{}, 1)
+	for _, q := range mc.queues {
+		q.rq.due = due
+	}
+	// timers are armed to the earliest scheduled tasks
+	err = mc.promote(initialCtx)
+	if err != nil {
+		return
+	}
+	subscriber := listener.Subscribe(initialCtx, channels...)
+	ticker := time.NewTicker(mc.heartbeat)
+	defer ticker.Stop()
+	presenceTicker := time.NewTicker(PresenceTimeout / 3)
+	defer presenceTicker.Stop()
+	sb := subscriber.Channel()
+	feed := make(chan delivery)
+	idle := make(chan struct{})
+	wake := make(chan struct{}, 1)
+	for _, q := range mc.queues {
+		q.rq.wake = wake
+	}
+
+	eg, ctx := errgroup.WithContext(initialCtx)
+
+	eg.Go(func() error {
+		defer func() {
+			// cleanup should be performed even if consumer context is canceled
+			ctx2, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultTaskTimeout)
+			defer cancel()
+			subscriber.Unsubscribe(ctx2, channels...)
+			subscriber.Close()
+		}()
+		// ready is true, when worker reported, that it is idle, and waits for task
+		ready := false
+		for {
+			if ready {
+				d, found, errN := mc.next(ctx)
+				if errN != nil {
+					return errN
+				}
+				if found {
+					select {
+					case <-ctx.Done():
+						// reserved task is returned to queue, when consumer leaves
+						return nil
+					case feed <- d:
+					}
+					ready = false
+					continue
+				}
+			}
+			// idle workers are not awaited, while one of them waits for task
+			var idleC chan struct{}
+			if !ready {
+				idleC = idle
+			}
+			// notifications and control messages are received, while all workers are busy
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-idleC:
+				ready = true
+			case msg := <-sb:
+				mc.control(msg)
+			case <-wake:
+			case <-due:
+				errP := mc.promote(ctx)
+				if errP != nil {
+					return errP
+				}
+			case <-ticker.C:
+				errP := mc.promote(ctx)
+				if errP != nil {
+					return errP
+				}
+			case <-presenceTicker.C:
+				errH := mc.housekeep(ctx)
+				if errH != nil {
+					return errH
+				}
+			}
+		}
+	})
+
+	for i := 0; i <= concurrency; i++ {
+		eg.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case idle <- struct{}{}:
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case d := <-feed:
+					errW := d.queue.rq.process(ctx, d.queue.worker, d.raw, i)
+					if errW != nil {
+						return errW
+					}
+				}
+			}
+		})
+	}
+
+	err = eg.Wait()
+	for _, q := range mc.queues {
+		q.rq.isConsumerRunning = false
+		ctx3, cancel := context.WithTimeout(context.WithoutCancel(initialCtx), q.rq.timeout)
+		errL := q.rq.leave(ctx3)
+		cancel()
+		if errL != nil && err == nil {
+			err = errL
+		}
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMultiConsumer_Consume(tt *testing.T) {
+	const n = 20
+	prepare := func(t *testing.T, names ...string) (queues []*RedisQueue) {
+		for _, name := range names {
+			rq, err := New(t.Context(), name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				rq.Purge(context.Background())
+				rq.Close()
+			})
+			err = rq.Purge(t.Context())
+			if err != nil {
+				t.Error(err)
+			}
+			for i := 0; i < n; i++ {
+				_, err = rq.Publish(t.Context(), fmt.Sprintf("%s %v", name, i))
+				if err != nil {
+					t.Error(err)
+				}
+			}
+			queues = append(queues, rq)
+		}
+		return
+	}
+	consume := func(t *testing.T, mc *MultiConsumer, queues []*RedisQueue, limit int) (order []string) {
+		cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		for _, rq := range queues {
+			err := mc.Add(rq, 0, func(ctx context.Context, payload string, indx int) error {
+				order = append(order, rq.GetQueueName())
+				if len(order) == limit {
+					cancel()
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		mc.SetHeartbeat(10 * time.Millisecond)
+		err := mc.Consume(cc, 0)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+		left := 0
+		for _, rq := range queues {
+			inFlight, errL := rq.client.LLen(t.Context(), rq.processingKey(rq.GetID())).Result()
+			if errL != nil {
+				t.Error(errL)
+			}
+			if inFlight != 0 {
+				t.Errorf("%v tasks are left in flight of %s", inFlight, rq.GetQueueName())
+			}
+			count, errC := rq.Count(t.Context())
+			if errC != nil {
+				t.Error(errC)
+			}
+			left += int(count)
+		}
+		if left+len(order) != n*len(queues) {
+			t.Errorf("tasks are lost: %v processed, %v left", len(order), left)
+		}
+		return
+	}
+
+	tt.Run("strict", func(t *testing.T) {
+		t.Parallel()
+		queues := prepare(t, "testMultiStrictUrgent", "testMultiStrictBulk")
+		order := consume(t, NewMultiConsumer(SelectStrict), queues, n+5)
+		if len(order) != n+5 {
+			t.Fatalf("wrong number of tasks processed %v", len(order))
+		}
+		for i := range order {
+			expected := "testMultiStrictUrgent"
+			if i >= n {
+				expected = "testMultiStrictBulk"
+			}
+			if order[i] != expected {
+				t.Errorf("task %v is taken from %s instead of %s", i, order[i], expected)
+			}
+		}
+	})
+
+	tt.Run("weighted", func(t *testing.T) {
+		t.Parallel()
+		queues := prepare(t, "testMultiWeightedHeavy", "testMultiWeightedLight")
+		mc := NewMultiConsumer(SelectWeighted)
+		cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		var order []string
+		for i, rq := range queues {
+			weight := 3
+			if i > 0 {
+				weight = 1
+			}
+			err := mc.Add(rq, weight, func(ctx context.Context, payload string, indx int) error {
+				order = append(order, rq.GetQueueName())
+				if len(order) == n {
+					cancel()
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		err := mc.Add(queues[0], 1, nil)
+		if err == nil {
+			t.Errorf("queue is added twice")
+		}
+		mc.SetHeartbeat(10 * time.Millisecond)
+		err = mc.Consume(cc, 0)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+		var heavy int
+		for i := range order {
+			if order[i] == "testMultiWeightedHeavy" {
+				heavy++
+			}
+		}
+		if heavy < 14 || heavy > 16 {
+			t.Errorf("wrong share of heavy queue: %v of %v", heavy, len(order))
+		}
+	})
+}
+
+func TestMultiConsumer_ConsumeBusy(t *testing.T) {
+	rq, err := New(t.Context(), "testMultiBusy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	started := make(chan struct{})
+	var cause error
+	var processed []string
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	mc := NewMultiConsumer(SelectStrict)
+	err = mc.Add(rq, 1, func(ctx context.Context, payload string, indx int) error {
+		if payload == "block" {
+			close(started)
+			<-ctx.Done()
+			cause = context.Cause(ctx)
+			return ctx.Err()
+		}
+		processed = append(processed, payload)
+		if len(processed) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	mc.SetHeartbeat(10 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		done <- mc.Consume(cc, 0)
+	}()
+	id, err := rq.Publish(t.Context(), "block")
+	if err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-started:
+	case <-cc.Done():
+		t.Fatalf("task is not started")
+	}
+	for i := 0; i < 3; i++ {
+		_, err = rq.Publish(t.Context(), fmt.Sprintf("next %v", i))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	// only running task is reserved, while the only worker is busy
+	inFlight, err := rq.client.LLen(t.Context(), rq.processingKey(rq.GetID())).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 1 {
+		t.Errorf("busy consumer holds %v tasks", inFlight)
+	}
+	result, err := rq.Cancel(t.Context(), id)
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelRunning {
+		t.Errorf("running task is %s", result)
+	}
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if !errors.Is(cause, ErrTaskCanceled) {
+		t.Errorf("worker context is canceled by %v", cause)
+	}
+	if len(processed) != 3 {
+		t.Errorf("tasks %v are processed after canceled one", processed)
+	}
+}
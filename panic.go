@@ -0,0 +1,48 @@
+package grq
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxPanics is number of attempts, during which worker panicked, after which task is quarantined
+const DefaultMaxPanics = 3
+
+// PanicError is returned by worker, that panicked while processing task
+type PanicError struct {
+	// Value is value passed to panic
+	Value any
+	// Stack is stack trace of goroutine, which panicked
+	Stack string
+}
+
+// Error returns value passed to panic
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("worker panicked: %v", pe.Value)
+}
+
+// SetMaxPanics sets number of attempts, during which worker panicked, after which task is quarantined -
+// moved to dead letter queue with stack trace of last panic, even if it has attempts left.
+// Zero means tasks are never quarantined and limited only by SetMaxAttempts.
+func (rq *RedisQueue) SetMaxPanics(n int) {
+	rq.maxPanics = n
+}
+
+// recoverWorker should be deferred by worker wrapper. It recovers panic of worker, records its stack trace
+// on span and replaces error returned by worker with PanicError
+func recoverWorker(span trace.Span, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	pe := &PanicError{Value: r, Stack: string(debug.Stack())}
+	span.SetStatus(codes.Error, pe.Error())
+	span.RecordError(pe, trace.WithAttributes(
+		attribute.String("exception.stacktrace", pe.Stack),
+	))
+	*err = pe
+}
@@ -0,0 +1,82 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PanicQuarantine(t *testing.T) {
+	const maxPanics = 2
+	rq, err := New(t.Context(), "testPanic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.PurgeDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	rq.SetMaxPanics(maxPanics)
+	_, err = rq.Publish(t.Context(), "poison message")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.Publish(t.Context(), "good message")
+	if err != nil {
+		t.Error(err)
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var panics, processed int
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		if payload == "poison message" {
+			panics++
+			if panics == maxPanics {
+				// give consumer some time to quarantine task
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+			var m map[string]int
+			m[payload]++ // assignment to nil map
+		}
+		processed++
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if panics != maxPanics {
+		t.Errorf("wrong number of panics %v", panics)
+	}
+	if processed != 1 {
+		t.Errorf("consumer processed %v tasks after panic", processed)
+	}
+	dead, err := rq.ListDead(t.Context(), 0, 10)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("wrong number of quarantined tasks %v", len(dead))
+	}
+	if dead[0].Panics != maxPanics {
+		t.Errorf("wrong number of panics %v of quarantined task", dead[0].Panics)
+	}
+	if !strings.Contains(dead[0].LastError, "assignment to entry in nil map") {
+		t.Errorf("wrong last error %s", dead[0].LastError)
+	}
+	if !strings.Contains(dead[0].Stack, "panic_test.go") {
+		t.Errorf("stack trace does not point to worker: %s", dead[0].Stack)
+	}
+	err = rq.PurgeDead(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,157 @@
+package grq
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// pauseMessage is published to channel of queue, when queue is paused
+	pauseMessage = "grq:pause"
+	// resumeMessage is published to channel of queue, when queue is resumed
+	resumeMessage = "grq:resume"
+)
+
+// unreserveScript returns task, that was moved to in-flight list of consumer, back to head of its priority level
+var unreserveScript = redis.NewScript(levelKeyLua + `
+if redis.call('LREM', KEYS[1], -1, ARGV[1]) > 0 then
+	redis.call('LPUSH', levelKey(ARGV[1], 2), ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// Pause stops all consumers of queue from taking new tasks, while tasks being processed are allowed to finish.
+// Flag is stored in redis, so queue stays paused for consumers started later, until Resume is called.
+// Tasks can still be published to paused queue.
+func (rq *RedisQueue) Pause(initialCtx context.Context) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Pause",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, rq.key("paused"), 1, 0)
+		pipe.Publish(ctx, ChannelPrefix+rq.name, pauseMessage)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	rq.setPaused(true)
+	return
+}
+
+// Resume allows consumers of queue to take tasks again, and tasks published while queue was paused
+// are taken right away
+func (rq *RedisQueue) Resume(initialCtx context.Context) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Resume",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, rq.key("paused"))
+		pipe.Publish(ctx, ChannelPrefix+rq.name, resumeMessage)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	rq.setPaused(false)
+	return
+}
+
+// IsPaused returns true, if queue is paused
+func (rq *RedisQueue) IsPaused(initialCtx context.Context) (paused bool, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.IsPaused",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Bool("paused", paused))
+		span.End()
+	}()
+	n, err := rq.client.Exists(ctx, rq.key("paused")).Result()
+	if err != nil {
+		return
+	}
+	return n > 0, nil
+}
+
+// setPaused updates paused state cached by consumer, and wakes it up, when queue is resumed
+func (rq *RedisQueue) setPaused(paused bool) {
+	if rq.paused.Swap(paused) == paused || paused {
+		return
+	}
+	// tasks published while queue was paused are taken right after resume
+	rq.wakeAfter(0)
+	select {
+	case rq.resumed <- struct{}{}:
+	default:
+	}
+}
+
+// syncPaused loads paused state of queue from redis, so consumer, that missed notification, catches up
+func (rq *RedisQueue) syncPaused(ctx context.Context) (err error) {
+	paused, err := rq.IsPaused(ctx)
+	if err != nil {
+		return
+	}
+	rq.setPaused(paused)
+	return
+}
+
+// control applies control message received from channel of queue, and returns false,
+// if message is ordinary notification about new task
+func (rq *RedisQueue) control(payload string) bool {
+	switch payload {
+	case pauseMessage:
+		rq.setPaused(true)
+	case resumeMessage:
+		rq.setPaused(false)
+	default:
+		switch {
+		case strings.HasPrefix(payload, cancelMessagePrefix):
+			rq.cancelRunning(payload)
+		case strings.HasPrefix(payload, scheduledMessagePrefix):
+			rq.scheduled(payload)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unreserve returns task, that was reserved by this consumer, but not processed, back to head of its priority level
+func (rq *RedisQueue) unreserve(ctx context.Context, raw string) (err error) {
+	return unreserveScript.Run(ctx, rq.client,
+		append([]string{rq.processingKey(rq.id)}, rq.levelKeys()...),
+		raw,
+	).Err()
+}
@@ -0,0 +1,194 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_Pause(t *testing.T) {
+	const tasks = 5
+	for _, blocking := range []bool{false, true} {
+		publisher, err := New(t.Context(), "testPause")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer publisher.Close()
+		err = publisher.Purge(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		consumer, err := New(t.Context(), "testPause")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer consumer.Close()
+		consumer.SetBlocking(blocking)
+		// consumer never polls queue by itself
+		consumer.SetHeartbeat(time.Hour)
+
+		var processed atomic.Int64
+		cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		done := make(chan error, 1)
+		go func() {
+			done <- consumer.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				if payload == "first" {
+					errP := publisher.Pause(ctx)
+					if errP != nil {
+						t.Error(errP)
+					}
+					// task being processed is allowed to finish
+					time.Sleep(100 * time.Millisecond)
+				}
+				processed.Add(1)
+				return nil
+			}, 2)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		_, err = publisher.Publish(t.Context(), "first")
+		if err != nil {
+			t.Error(err)
+		}
+		time.Sleep(300 * time.Millisecond)
+		paused, err := publisher.IsPaused(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if !paused {
+			t.Errorf("queue is not paused")
+		}
+		for i := 0; i < tasks; i++ {
+			_, err = publisher.Publish(t.Context(), "task")
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		time.Sleep(300 * time.Millisecond)
+		if n := processed.Load(); n != 1 {
+			t.Errorf("%v tasks are processed by paused consumer with blocking %v", n, blocking)
+		}
+		err = publisher.Resume(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		paused, err = publisher.IsPaused(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if paused {
+			t.Errorf("queue is not resumed")
+		}
+		for start := time.Now(); processed.Load() < tasks+1 && time.Since(start) < 2*time.Second; {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := processed.Load(); n != tasks+1 {
+			t.Errorf("%v tasks are processed after resume with blocking %v", n, blocking)
+		}
+		cancel()
+		err = <-done
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRedisQueue_PauseBacklog(t *testing.T) {
+	const tasks = 20
+	for _, blocking := range []bool{false, true} {
+		publisher, err := New(t.Context(), "testPauseBacklog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer publisher.Close()
+		err = publisher.Purge(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		err = publisher.Resume(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		// backlog is published before consumers are started
+		for i := 0; i < tasks; i++ {
+			_, err = publisher.Publish(t.Context(), fmt.Sprintf("task %v", i))
+			if err != nil {
+				t.Error(err)
+			}
+		}
+
+		var started atomic.Int64
+		var resumed atomic.Bool
+		afterResume := make([]atomic.Int64, 2)
+		consumers := make([]*RedisQueue, len(afterResume))
+		cc, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		wg := sync.WaitGroup{}
+		for i := range consumers {
+			consumers[i], err = New(t.Context(), "testPauseBacklog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer consumers[i].Close()
+			consumers[i].SetBlocking(blocking)
+			// consumers never poll queue by themselves
+			consumers[i].SetHeartbeat(time.Hour)
+			wg.Go(func() {
+				errC := consumers[i].ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+					started.Add(1)
+					if resumed.Load() {
+						afterResume[i].Add(1)
+					}
+					time.Sleep(50 * time.Millisecond)
+					return nil
+				}, 0)
+				if errC != nil && !errors.Is(errC, context.Canceled) {
+					t.Error(errC)
+				}
+			})
+		}
+		for start := time.Now(); started.Load() < 2 && time.Since(start) < 2*time.Second; {
+			time.Sleep(5 * time.Millisecond)
+		}
+		err = consumers[0].Pause(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		// the other consumer receives notification about pause
+		time.Sleep(100 * time.Millisecond)
+		startedAtPause := started.Load()
+		time.Sleep(300 * time.Millisecond)
+		if n := started.Load() - startedAtPause; n > 0 {
+			t.Errorf("%v tasks are started by paused consumers with blocking %v", n, blocking)
+		}
+		pending, err := publisher.Count(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if pending != tasks-startedAtPause {
+			t.Errorf("%v tasks are left in paused queue instead of %v with blocking %v",
+				pending, tasks-startedAtPause, blocking)
+		}
+
+		resumed.Store(true)
+		err = publisher.Resume(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		for start := time.Now(); started.Load() < tasks && time.Since(start) < 5*time.Second; {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := started.Load(); n != tasks {
+			t.Errorf("%v tasks are processed instead of %v with blocking %v", n, tasks, blocking)
+		}
+		for i := range afterResume {
+			if afterResume[i].Load() == 0 {
+				t.Errorf("consumer %v is starved after resume with blocking %v", i, blocking)
+			}
+		}
+		cancel()
+		wg.Wait()
+	}
+}
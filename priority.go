@@ -0,0 +1,188 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Priority is priority level of task. Consumers process tasks of higher levels first,
+// while tasks of the same level are processed in order they were published.
+type Priority int
+
+const (
+	// PriorityLow is for tasks, that can wait, like newsletters
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is priority of tasks published by Publish. Tasks of this level are stored in list
+	// named after queue, so tasks pushed by other clients, like redis-cli, have this priority too
+	PriorityNormal
+	// PriorityHigh is for tasks, that should be processed before normal ones
+	PriorityHigh
+	// PriorityCritical is for tasks, that should be processed before all others, like password reset mails
+	PriorityCritical
+)
+
+// String returns name of priority level
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// valid checks, if priority is one of levels supported
+func (p Priority) valid() bool {
+	return p >= PriorityLow && p <= PriorityCritical
+}
+
+// levelKey returns name of list, where tasks of priority level provided are stored
+func (rq *RedisQueue) levelKey(p Priority) string {
+	if p == PriorityNormal {
+		return rq.name
+	}
+	return rq.key(p.String())
+}
+
+// levelKeys returns names of lists of all priority levels, from lowest to highest
+func (rq *RedisQueue) levelKeys() (keys []string) {
+	for p := PriorityLow; p <= PriorityCritical; p++ {
+		keys = append(keys, rq.levelKey(p))
+	}
+	return
+}
+
+// levelKeyLua is Lua function, that returns list of priority level, which task provided belongs to.
+// Lists of all priority levels should be passed in KEYS from lowest to highest, starting from index base.
+// Raw tasks pushed by other clients have normal priority.
+var levelKeyLua = fmt.Sprintf(`
+local function levelKey(raw, base)
+	local level = %d
+	if string.sub(raw, 1, %d) == '%s' then
+		local ok, task = pcall(cjson.decode, raw)
+		if ok and type(task) == 'table' and type(task.priority) == 'number'
+			and task.priority >= %d and task.priority <= %d and task.priority == math.floor(task.priority) then
+			level = task.priority
+		end
+	end
+	return KEYS[base + level - (%d)]
+end
+`, PriorityNormal, len(envelopePrefix), envelopePrefix, PriorityLow, PriorityCritical, PriorityLow)
+
+// reserveLua is Lua function, that moves first task of the highest non-empty priority level into in-flight list.
+// In-flight list should be passed in KEYS[1], and n lists of priority levels follow it from lowest to highest.
+// If weights of levels are provided in args after random number, level is chosen randomly among non-empty ones
+// in proportion to their weights, so tasks of lower levels are not starved.
+const reserveLua = `
+local function reserve(n, args)
+	if #args > 1 then
+		local total = 0
+		local nonEmpty = {}
+		for i = 1, n do
+			if redis.call('LLEN', KEYS[i + 1]) > 0 then
+				nonEmpty[i] = true
+				total = total + tonumber(args[i + 1])
+			end
+		end
+		if total > 0 then
+			local target = tonumber(args[1]) * total
+			for i = n, 1, -1 do
+				if nonEmpty[i] then
+					local weight = tonumber(args[i + 1])
+					if target < weight then
+						return redis.call('LMOVE', KEYS[i + 1], KEYS[1], 'LEFT', 'RIGHT')
+					end
+					target = target - weight
+				end
+			end
+		end
+	end
+	for i = n, 1, -1 do
+		local raw = redis.call('LMOVE', KEYS[i + 1], KEYS[1], 'LEFT', 'RIGHT')
+		if raw then
+			return raw
+		end
+	end
+	return false
+end
+`
+
+// reserveScript moves first task of the highest non-empty priority level, or of level chosen by weights,
+// into in-flight list
+var reserveScript = redis.NewScript(reserveLua + `
+return reserve(#KEYS - 1, ARGV)
+`)
+
+// SetPriorityWeights enables anti-starvation weighting of priority levels. Instead of always draining
+// higher levels first, consumer chooses level among non-empty ones randomly in proportion to its weight,
+// so, for example, weights {PriorityCritical: 8, PriorityNormal: 2, PriorityLow: 1} make consumer pick
+// low priority task roughly once per 11 tasks even if queue is flooded by critical ones.
+// Levels not mentioned have zero weight and are processed only when all weighted levels are empty.
+// Nil or empty weights restore strict priority order.
+func (rq *RedisQueue) SetPriorityWeights(weights map[Priority]int) {
+	if len(weights) == 0 {
+		rq.priorityWeights = nil
+		return
+	}
+	rq.priorityWeights = make([]int, 0, PriorityCritical-PriorityLow+1)
+	for p := PriorityLow; p <= PriorityCritical; p++ {
+		rq.priorityWeights = append(rq.priorityWeights, max(weights[p], 0))
+	}
+}
+
+// reserveArgs returns arguments of reserveScript for weights of priority levels set
+func (rq *RedisQueue) reserveArgs() (args []any) {
+	if rq.priorityWeights == nil {
+		return nil
+	}
+	args = append(args, rand.Float64())
+	for _, w := range rq.priorityWeights {
+		args = append(args, w)
+	}
+	return args
+}
+
+// PublishWithPriority sends task to channel with priority level provided. Tasks of higher levels are
+// processed first, and tasks of the same level are processed in order they were published.
+// Unique id of task is returned.
+func (rq *RedisQueue) PublishWithPriority(initialCtx context.Context, prio Priority, p any) (id string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishWithPriority",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("priority", prio.String()),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if !prio.valid() {
+		return "", fmt.Errorf("unknown priority level %s", prio)
+	}
+	task, err := rq.newTask(p)
+	if err != nil {
+		return
+	}
+	task.Priority = prio
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	err = rq.push(ctx, task, false)
+	return task.ID, err
+}
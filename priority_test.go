@@ -0,0 +1,140 @@
+package grq
+
+import (
+	"testing"
+)
+
+func TestRedisQueue_PublishWithPriority(t *testing.T) {
+	rq, err := New(t.Context(), "testPriority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	published := []struct {
+		prio    Priority
+		payload string
+	}{
+		{PriorityLow, "newsletter"},
+		{PriorityNormal, "normal 1"},
+		{PriorityCritical, "password reset 1"},
+		{PriorityHigh, "invoice"},
+		{PriorityNormal, "normal 2"},
+		{PriorityCritical, "password reset 2"},
+	}
+	for i := range published {
+		_, err = rq.PublishWithPriority(t.Context(), published[i].prio, published[i].payload)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	_, err = rq.PublishWithPriority(t.Context(), PriorityCritical+1, "unknown")
+	if err == nil {
+		t.Errorf("task with unknown priority is published")
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != int64(len(published)) {
+		t.Errorf("wrong number of tasks %v", n)
+	}
+
+	// task returned to queue keeps its priority
+	raw, found, err := rq.reserve(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found || decodeTask(raw).Payload != "password reset 1" {
+		t.Errorf("wrong task reserved %s", raw)
+	}
+	_, err = rq.restore(t.Context(), rq.id)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{"password reset 1", "password reset 2", "invoice", "normal 1", "normal 2", "newsletter"}
+	for i := range expected {
+		payload, found, errG := rq.GetTask(t.Context())
+		if errG != nil {
+			t.Error(errG)
+		}
+		if !found || payload != expected[i] {
+			t.Errorf("wrong task %v received: %s instead of %s", i, payload, expected[i])
+		}
+	}
+	_, found, err = rq.GetTask(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if found {
+		t.Errorf("queue is not empty")
+	}
+}
+
+func TestRedisQueue_SetPriorityWeights(t *testing.T) {
+	const n = 50
+	rq, err := New(t.Context(), "testPriorityWeights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < n; i++ {
+		_, err = rq.PublishWithPriority(t.Context(), PriorityCritical, "critical")
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = rq.PublishWithPriority(t.Context(), PriorityLow, "low")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	rq.SetPriorityWeights(map[Priority]int{PriorityCritical: 1, PriorityLow: 1})
+	var low int
+	for i := 0; i < n; i++ {
+		raw, found, errR := rq.reserve(t.Context())
+		if errR != nil {
+			t.Error(errR)
+		}
+		if !found {
+			t.Fatalf("task is not reserved")
+		}
+		if decodeTask(raw).Payload == "low" {
+			low++
+		}
+		err = rq.ack(t.Context(), raw)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if low == 0 || low == n {
+		t.Errorf("low priority tasks are not weighted: %v of %v reserved", low, n)
+	}
+
+	rq.SetPriorityWeights(nil)
+	raw, found, err := rq.reserve(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found {
+		t.Fatalf("task is not reserved")
+	}
+	if low < n && decodeTask(raw).Payload != "critical" {
+		t.Errorf("strict priority order is not restored")
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.processingKey(rq.id)).Err()
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,220 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rejectScript replaces task from in-flight list by its next attempt at the tail of its priority level, if task is still
+// in in-flight list, and notifies consumers about it
+var rejectScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[2], ARGV[2])
+redis.call('PUBLISH', ARGV[3], '1')
+return 1
+`)
+
+// restoreScript moves all tasks from in-flight list back to the head of their priority levels,
+// preserving their order, and notifies consumers about it
+var restoreScript = redis.NewScript(levelKeyLua + `
+local n = 0
+local raw = redis.call('RPOP', KEYS[1])
+while raw do
+	redis.call('LPUSH', levelKey(raw, 2), raw)
+	n = n + 1
+	raw = redis.call('RPOP', KEYS[1])
+end
+if n > 0 then
+	redis.call('PUBLISH', ARGV[1], '1')
+end
+return n
+`)
+
+// reserve atomically moves first task of the highest priority level into in-flight list of this consumer,
+// so task is not lost, if consumer dies while processing it. If global concurrency is limited, and all slots
+// are busy, nothing is reserved. If rate limit is set, and it is exceeded, nothing is reserved too,
+// and consumer is woken up, when next task is allowed. Permission of rate limiter is spent in the same script,
+// which reserves task, so it is not wasted, when other consumer takes the last task.
+func (rq *RedisQueue) reserve(initialCtx context.Context) (raw string, found bool, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.reserve",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Bool("found", found))
+		span.End()
+	}()
+	if rq.paused.Load() {
+		span.AddEvent("queue is paused")
+		return
+	}
+	if rq.globalConcurrency > 0 {
+		var acquired bool
+		acquired, err = rq.acquireSlot(ctx)
+		if err != nil || !acquired {
+			return
+		}
+		defer func() {
+			if !found {
+				// slot is not needed, if nothing is reserved
+				errS := rq.releaseSlot(context.WithoutCancel(ctx))
+				if errS != nil && err == nil {
+					err = errS
+				}
+			}
+		}()
+	}
+	if rq.rateLimit.Rate > 0 {
+		var wait time.Duration
+		raw, found, wait, err = rq.reserveLimited(ctx)
+		if err != nil {
+			return
+		}
+		if !found {
+			if wait > 0 {
+				span.AddEvent("rate limit is exceeded", trace.WithAttributes(
+					attribute.String("wait", wait.String()),
+				))
+				rq.wakeAfter(wait)
+			} else {
+				span.AddEvent("nothing found")
+			}
+			return
+		}
+	} else {
+		raw, err = reserveScript.Run(ctx, rq.client,
+			append([]string{rq.processingKey(rq.id)}, rq.levelKeys()...),
+			rq.reserveArgs()...,
+		).Text()
+		if err != nil {
+			if err == redis.Nil {
+				span.AddEvent("nothing found")
+				return "", false, nil
+			}
+			return
+		}
+	}
+	span.AddEvent("task is reserved")
+	span.SetStatus(codes.Ok, "task is reserved")
+	found = true
+	return
+}
+
+// ack removes task from in-flight list of this consumer, so it will never be delivered again
+func (rq *RedisQueue) ack(ctx context.Context, raw string) (err error) {
+	return rq.client.LRem(ctx, rq.processingKey(rq.id), 1, raw).Err()
+}
+
+// reject replaces task from in-flight list of this consumer by its next attempt in queue,
+// so it can be processed again
+func (rq *RedisQueue) reject(ctx context.Context, raw string, next Task) (err error) {
+	nextRaw, err := next.encode()
+	if err != nil {
+		return
+	}
+	return rejectScript.Run(ctx, rq.client,
+		[]string{rq.processingKey(rq.id), rq.levelKey(next.Priority)},
+		raw, nextRaw, ChannelPrefix+rq.name,
+	).Err()
+}
+
+// isRemoved checks, if task reserved by this consumer is already removed from its in-flight list by Cancel
+func (rq *RedisQueue) isRemoved(ctx context.Context, raw string) (removed bool, err error) {
+	err = rq.client.LPos(ctx, rq.processingKey(rq.id), raw, redis.LPosArgs{}).Err()
+	if err == redis.Nil {
+		return true, nil
+	}
+	return false, err
+}
+
+// restore moves all tasks left in in-flight list of consumer with id provided back to queue
+func (rq *RedisQueue) restore(ctx context.Context, consumerID string) (n int64, err error) {
+	return restoreScript.Run(ctx, rq.client,
+		append([]string{rq.processingKey(consumerID)}, rq.levelKeys()...),
+		ChannelPrefix+rq.name,
+	).Int64()
+}
+
+// process executes worker against task reserved by this consumer, and acknowledges task, if worker
+// succeeded, or registers failed attempt, if worker failed. Expired tasks are not passed to worker,
+// and tasks, that reached worker after queue was paused, are returned to queue.
+func (rq *RedisQueue) process(ctx context.Context, worker WorkerFunc, raw string, indx int) (err error) {
+	// task should be acknowledged even if consumer is stopping right now
+	ackCtx := context.WithoutCancel(ctx)
+	if rq.globalConcurrency > 0 {
+		defer func() {
+			ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+			defer cancel()
+			errS := rq.releaseSlot(ctx2)
+			if errS != nil && err == nil {
+				err = errS
+			}
+		}()
+	}
+	if rq.paused.Load() {
+		// queue was paused, while task was passed to worker
+		ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+		defer cancel()
+		return rq.unreserve(ctx2, raw)
+	}
+	task := decodeTask(raw)
+	if rq.isExpired(task, time.Now()) {
+		ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+		defer cancel()
+		return rq.expire(ctx2, raw, task, true)
+	}
+	holder := &resultHolder{}
+	taskCtx, done := rq.track(context.WithValue(withTask(ctx, task), resultHolderKey{}, holder), task.ID)
+	// task could be canceled, while it was waiting for idle worker
+	canceled := errors.Is(context.Cause(taskCtx), ErrTaskCanceled)
+	if !canceled {
+		// mark of cancel is forgotten, if task waited for worker too long, but canceled task
+		// is removed from in-flight list anyway
+		ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+		canceled, err = rq.isRemoved(ctx2, raw)
+		cancel()
+		if err != nil {
+			done()
+			return
+		}
+	}
+	var errW error
+	if canceled {
+		done()
+	} else {
+		timeout := rq.timeout
+		if task.Headers[HeaderReplyTo] != "" && !task.ExpiresAt.IsZero() {
+			// worker of call does not outlive caller
+			timeout = min(timeout, time.Until(task.ExpiresAt))
+		}
+		lease, workerCtx := rq.newLease(taskCtx, timeout)
+		errW = rq.wrapWorker(worker)(workerCtx, task.Payload, indx)
+		lease.release()
+		canceled = done()
+	}
+
+	ctx2, cancel := context.WithTimeout(ackCtx, rq.timeout)
+	defer cancel()
+	if canceled {
+		// canceled task is already removed from in-flight list
+		return rq.finish(ctx2, task, ResultCanceled, "")
+	}
+	if errW != nil {
+		return rq.fail(ctx2, raw, task, errW)
+	}
+	return rq.complete(ctx2, raw, task, holder.value)
+}
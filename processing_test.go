@@ -0,0 +1,105 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_ReserveAndAck(t *testing.T) {
+	rq, err := New(t.Context(), "testReserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.Publish(t.Context(), "reserved task")
+	if err != nil {
+		t.Error(err)
+	}
+	raw, found, err := rq.reserve(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found {
+		t.Errorf("task not reserved")
+	}
+	if decodeTask(raw).Payload != "reserved task" {
+		t.Errorf("wrong payload %s", decodeTask(raw).Payload)
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("reserved task is still in queue")
+	}
+	inFlight, err := rq.client.LLen(t.Context(), rq.processingKey(rq.id)).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 1 {
+		t.Errorf("wrong number of tasks in flight: %v", inFlight)
+	}
+	err = rq.ack(t.Context(), raw)
+	if err != nil {
+		t.Error(err)
+	}
+	inFlight, err = rq.client.LLen(t.Context(), rq.processingKey(rq.id)).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 0 {
+		t.Errorf("acknowledged task is still in flight")
+	}
+}
+
+func TestRedisQueue_ConsumeRestoresUnfinishedTasks(t *testing.T) {
+	rq, err := New(t.Context(), "testRestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	_, err = rq.Publish(t.Context(), "task interrupted by shutdown")
+	if err != nil {
+		t.Error(err)
+	}
+	cc, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		// consumer is stopped while task is being processed
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}, 1)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("unfinished task is not returned to queue, %v tasks left", n)
+	}
+	inFlight, err := rq.client.LLen(t.Context(), rq.processingKey(rq.id)).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 0 {
+		t.Errorf("%v tasks are left in flight", inFlight)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -3,15 +3,19 @@ package grq
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Publish sends task to channel
-func (rq *RedisQueue) Publish(initialCtx context.Context, p any) (err error) {
+// Publish sends task to channel, anything that can be stringified by fmt.Sprint can be used as payload.
+// Unique id of task is returned.
+func (rq *RedisQueue) Publish(initialCtx context.Context, p any) (id string, err error) {
 	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Publish",
 		trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(attribute.String("queue", rq.name)),
@@ -24,16 +28,18 @@ func (rq *RedisQueue) Publish(initialCtx context.Context, p any) (err error) {
 		}
 		span.End()
 	}()
-	err = rq.client.RPush(ctx, rq.name, fmt.Sprint(p)).Err()
+	task, err := rq.newTask(p)
 	if err != nil {
 		return
 	}
-	err = rq.client.Publish(ctx, fmt.Sprintf("%s%s", ChannelPrefix, rq.name), "1").Err()
-	return
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	err = rq.push(ctx, task, false)
+	return task.ID, err
 }
 
-// PublishFirst sends task to channel in way it will be executed before all other tasks
-func (rq *RedisQueue) PublishFirst(initialCtx context.Context, p interface{}) (err error) {
+// PublishFirst sends task to channel in way it will be executed before all other tasks.
+// Unique id of task is returned.
+func (rq *RedisQueue) PublishFirst(initialCtx context.Context, p any) (id string, err error) {
 	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishFirst",
 		trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(attribute.String("queue", rq.name)),
@@ -46,7 +52,61 @@ func (rq *RedisQueue) PublishFirst(initialCtx context.Context, p interface{}) (e
 		}
 		span.End()
 	}()
-	err = rq.client.LPush(ctx, rq.name, fmt.Sprint(p)).Err()
+	task, err := rq.newTask(p)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	err = rq.push(ctx, task, true)
+	return task.ID, err
+}
+
+// PublishTask sends task with custom content type, headers and priority to channel. If task has no ID, it is generated,
+// and time of publishing, producer and attempt number are set by this function. Unique id of task is returned.
+func (rq *RedisQueue) PublishTask(initialCtx context.Context, task Task) (id string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishTask",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if task.ID == "" {
+		task.ID, err = getRandomID()
+		if err != nil {
+			return
+		}
+	}
+	if strings.Contains(task.ID, ":") {
+		return "", fmt.Errorf("task id %s should not contain colon", task.ID)
+	}
+	if !task.Priority.valid() {
+		return "", fmt.Errorf("unknown priority level %s", task.Priority)
+	}
+	task.EnqueuedAt = time.Now()
+	task.Attempt = 1
+	task.Producer = rq.id
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	err = rq.push(ctx, task, false)
+	return task.ID, err
+}
+
+// push sends task to the tail of its priority level, or to its head, if first is true, and notifies consumers about it
+func (rq *RedisQueue) push(ctx context.Context, task Task, first bool) (err error) {
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	if first {
+		err = rq.client.LPush(ctx, rq.levelKey(task.Priority), raw).Err()
+	} else {
+		err = rq.client.RPush(ctx, rq.levelKey(task.Priority), raw).Err()
+	}
 	if err != nil {
 		return
 	}
@@ -54,7 +114,7 @@ func (rq *RedisQueue) PublishFirst(initialCtx context.Context, p interface{}) (e
 	return
 }
 
-// Count counts tasks currently in queue
+// Count counts tasks currently in queue, including all priority levels
 func (rq *RedisQueue) Count(initialCtx context.Context) (n int64, err error) {
 	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Count",
 		trace.WithSpanKind(trace.SpanKindProducer),
@@ -68,11 +128,22 @@ func (rq *RedisQueue) Count(initialCtx context.Context) (n int64, err error) {
 		}
 		span.End()
 	}()
-	n, err = rq.client.LLen(ctx, rq.name).Result()
+	pipe := rq.client.Pipeline()
+	lens := make([]*redis.IntCmd, 0, PriorityCritical-PriorityLow+1)
+	for _, key := range rq.levelKeys() {
+		lens = append(lens, pipe.LLen(ctx, key))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return
+	}
+	for i := range lens {
+		n += lens[i].Val()
+	}
 	return
 }
 
-// Purge discards all tasks in queue
+// Purge discards all tasks in queue, including scheduled ones and ones of all priority levels
 func (rq *RedisQueue) Purge(initialCtx context.Context) (err error) {
 	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Purge",
 		trace.WithSpanKind(trace.SpanKindProducer),
@@ -86,6 +157,65 @@ func (rq *RedisQueue) Purge(initialCtx context.Context) (err error) {
 		}
 		span.End()
 	}()
-	err = rq.client.Del(ctx, rq.name).Err()
+	err = rq.client.Del(ctx, append(rq.levelKeys(), rq.key("scheduled"))...).Err()
+	return
+}
+
+// PublishAt sends task to channel in way it will be available for consumers not earlier than at moment provided.
+// Consumers are notified about it, so they move task to queue, when it is due. Unique id of task is returned.
+func (rq *RedisQueue) PublishAt(initialCtx context.Context, at time.Time, p any) (id string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishAt",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("at", at.Format(time.RFC3339)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	task, err := rq.newTask(p)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	member, err := scheduledMember(task)
+	if err != nil {
+		return
+	}
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, rq.key("scheduled"), redis.Z{Score: float64(at.UnixMilli()), Member: member})
+		pipe.Publish(ctx, ChannelPrefix+rq.name, fmt.Sprintf("%s%d", scheduledMessagePrefix, at.UnixMilli()))
+		return nil
+	})
+	return task.ID, err
+}
+
+// PublishIn sends task to channel in way it will be available for consumers after delay provided.
+// Unique id of task is returned.
+func (rq *RedisQueue) PublishIn(ctx context.Context, delay time.Duration, p any) (id string, err error) {
+	return rq.PublishAt(ctx, time.Now().Add(delay), p)
+}
+
+// CountScheduled counts tasks scheduled to be published in future
+func (rq *RedisQueue) CountScheduled(initialCtx context.Context) (n int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.CountScheduled",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	n, err = rq.client.ZCard(ctx, rq.key("scheduled")).Result()
 	return
 }
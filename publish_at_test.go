@@ -0,0 +1,159 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PublishAt(t *testing.T) {
+	rq, err := New(t.Context(), "testPublishAt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishAt(t.Context(), time.Now().Add(-time.Second), "overdue")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishAt(t.Context(), time.Now().Add(time.Hour), "later")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishAt(t.Context(), time.Now().Add(time.Hour), "later")
+	if err != nil {
+		t.Error(err)
+	}
+	n, err := rq.CountScheduled(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 3 {
+		t.Errorf("wrong number of scheduled tasks %v", n)
+	}
+	promoted, err := rq.promote(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if promoted != 1 {
+		t.Errorf("wrong number of promoted tasks %v", promoted)
+	}
+	payload, found, err := rq.GetTask(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found || payload != "overdue" {
+		t.Errorf("wrong promoted task %s", payload)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	n, err = rq.CountScheduled(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("scheduled tasks are not purged")
+	}
+}
+
+func TestRedisQueue_PublishIn(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	rq, err := New(t.Context(), "testPublishIn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	publishedAt := time.Now()
+	_, err = rq.PublishIn(t.Context(), delay, "reminder")
+	if err != nil {
+		t.Error(err)
+	}
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var receivedAt time.Time
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		if payload != "reminder" {
+			t.Errorf("wrong payload %s", payload)
+		}
+		receivedAt = time.Now()
+		cancel()
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if receivedAt.Sub(publishedAt) < delay {
+		t.Errorf("task is received too early, after %s", receivedAt.Sub(publishedAt))
+	}
+}
+
+func TestRedisQueue_PublishInOnTime(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	publisher, err := New(t.Context(), "testPublishInOnTime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	consumer, err := New(t.Context(), "testPublishInOnTime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	// consumer never polls queue by itself
+	consumer.SetHeartbeat(time.Hour)
+
+	mu := sync.Mutex{}
+	due := map[string]time.Time{"before start": time.Now().Add(delay)}
+	_, err = publisher.PublishAt(t.Context(), due["before start"], "before start")
+	if err != nil {
+		t.Error(err)
+	}
+	time.AfterFunc(delay, func() {
+		at := time.Now().Add(delay)
+		mu.Lock()
+		due["while running"] = at
+		mu.Unlock()
+		_, errP := publisher.PublishAt(context.Background(), at, "while running")
+		if errP != nil {
+			t.Error(errP)
+		}
+	})
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	received := make(map[string]time.Time)
+	err = consumer.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		received[payload] = time.Now()
+		if len(received) == 2 {
+			cancel()
+		}
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for payload, at := range due {
+		late := received[payload].Sub(at)
+		if late < 0 || late > delay {
+			t.Errorf("task %s is received %s after it is due", payload, late)
+		}
+	}
+}
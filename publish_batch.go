@@ -0,0 +1,121 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// publishBatchChunk limits number of tasks pushed to queue by one command
+const publishBatchChunk = 1000
+
+// PublishBatch sends many tasks to channel by one pipeline and notifies consumers once.
+// Tasks are executed in order they are provided. Unique ids of tasks are returned in the same order.
+// If some tasks are not published, BatchError with their indexes is returned, and their ids are empty.
+func (rq *RedisQueue) PublishBatch(initialCtx context.Context, payloads ...any) (ids []string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishBatch",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("batch_size", len(payloads)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	return rq.pushBatch(ctx, payloads, false)
+}
+
+// PublishFirstBatch sends many tasks to channel by one pipeline in way they will be executed before all other
+// tasks, and notifies consumers once. Tasks of batch are executed in order they are provided.
+// Unique ids of tasks are returned in the same order. If some tasks are not published, BatchError
+// with their indexes is returned, and their ids are empty.
+func (rq *RedisQueue) PublishFirstBatch(initialCtx context.Context, payloads ...any) (ids []string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishFirstBatch",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("batch_size", len(payloads)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	return rq.pushBatch(ctx, payloads, true)
+}
+
+// pushBatch sends tasks to the tail of queue, or to its head, if first is true, by chunks in one pipeline,
+// and notifies consumers once
+func (rq *RedisQueue) pushBatch(ctx context.Context, payloads []any, first bool) (ids []string, err error) {
+	if len(payloads) == 0 {
+		return nil, nil
+	}
+	ids = make([]string, len(payloads))
+	raws := make([]any, len(payloads))
+	for i := range payloads {
+		task, errT := rq.newTask(payloads[i])
+		if errT != nil {
+			return nil, errT
+		}
+		raws[i], err = task.encode()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = task.ID
+	}
+	type chunk struct {
+		from, to int
+		cmd      *redis.IntCmd
+	}
+	var chunks []chunk
+	pipe := rq.client.Pipeline()
+	if first {
+		// LPUSH puts every value before previous one, so chunks and their values are pushed in reverse order
+		for to := len(raws); to > 0; to -= publishBatchChunk {
+			from := max(to-publishBatchChunk, 0)
+			values := slices.Clone(raws[from:to])
+			slices.Reverse(values)
+			chunks = append(chunks, chunk{from: from, to: to, cmd: pipe.LPush(ctx, rq.name, values...)})
+		}
+	} else {
+		for from := 0; from < len(raws); from += publishBatchChunk {
+			to := min(from+publishBatchChunk, len(raws))
+			chunks = append(chunks, chunk{from: from, to: to, cmd: pipe.RPush(ctx, rq.name, raws[from:to]...)})
+		}
+	}
+	notification := pipe.Publish(ctx, ChannelPrefix+rq.name, "1")
+	_, err = pipe.Exec(ctx)
+	failed := make(BatchError)
+	for _, c := range chunks {
+		if c.cmd.Err() == nil {
+			continue
+		}
+		for i := c.from; i < c.to; i++ {
+			failed[i] = c.cmd.Err()
+			ids[i] = ""
+		}
+	}
+	if len(failed) > 0 {
+		return ids, failed
+	}
+	if notification.Err() != nil {
+		return ids, fmt.Errorf("%w : while notifying consumers about published tasks", notification.Err())
+	}
+	return ids, err
+}
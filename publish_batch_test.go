@@ -0,0 +1,97 @@
+package grq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRedisQueue_PublishBatch(t *testing.T) {
+	const n = 2500
+	rq, err := New(t.Context(), "testPublishBatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	payloads := make([]any, n)
+	for i := range payloads {
+		payloads[i] = i
+	}
+	ids, err := rq.PublishBatch(t.Context(), payloads...)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ids) != n {
+		t.Errorf("wrong number of ids %v", len(ids))
+	}
+	urgent := make([]any, n)
+	for i := range urgent {
+		urgent[i] = fmt.Sprintf("urgent %v", i)
+	}
+	_, err = rq.PublishFirstBatch(t.Context(), urgent...)
+	if err != nil {
+		t.Error(err)
+	}
+	count, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 2*n {
+		t.Errorf("wrong number of tasks in queue %v", count)
+	}
+	expected := append(urgent, payloads...)
+	for i := range expected {
+		raw, found, errR := rq.reserve(t.Context())
+		if errR != nil {
+			t.Fatal(errR)
+		}
+		if !found {
+			t.Fatalf("task %v is not found", i)
+		}
+		task := decodeTask(raw)
+		if task.Payload != fmt.Sprint(expected[i]) {
+			t.Fatalf("wrong task %v: %s instead of %v", i, task.Payload, expected[i])
+		}
+		if i >= n && task.ID != ids[i-n] {
+			t.Errorf("wrong id %s of task %v", task.ID, i)
+		}
+		err = rq.ack(t.Context(), raw)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRedisQueue_PublishBatchFailure(t *testing.T) {
+	rq, err := New(t.Context(), "testPublishBatchFailure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	// queue key has wrong type, so tasks cannot be pushed
+	err = rq.client.Set(t.Context(), rq.GetQueueName(), "not a list", 0).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	ids, err := rq.PublishBatch(t.Context(), "a", "b", "c")
+	var be BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("wrong error %v", err)
+	}
+	if len(be) != 3 {
+		t.Errorf("wrong number of failed tasks %v", len(be))
+	}
+	for i := range ids {
+		if ids[i] != "" {
+			t.Errorf("failed task %v has id %s", i, ids[i])
+		}
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
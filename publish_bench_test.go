@@ -20,7 +20,7 @@ func BenchmarkRedisQueue_Publish(b *testing.B) {
 
 	b.SetParallelism(runtime.NumCPU())
 	for b.Loop() {
-		err = publisher.Publish(b.Context(), time.Now().UnixNano())
+		_, err = publisher.Publish(b.Context(), time.Now().UnixNano())
 		if err != nil {
 			b.Errorf("%s : while publishing task %v", err, b.N)
 		}
@@ -37,3 +37,39 @@ func BenchmarkRedisQueue_Publish(b *testing.B) {
 		b.Errorf("%s : while purging benchmark queue", err)
 	}
 }
+
+func BenchmarkRedisQueue_PublishBatch(b *testing.B) {
+	const batchSize = 100
+	publisher, err := New(b.Context(), "benchBatch")
+	if err != nil {
+		b.Errorf("%s : while creating benchmark publisher", err)
+	}
+
+	err = publisher.Ping(b.Context())
+	if err != nil {
+		b.Errorf("%s : while pinging", err)
+		return
+	}
+
+	payloads := make([]any, batchSize)
+	for b.Loop() {
+		for i := range payloads {
+			payloads[i] = time.Now().UnixNano()
+		}
+		_, err = publisher.PublishBatch(b.Context(), payloads...)
+		if err != nil {
+			b.Errorf("%s : while publishing batch %v", err, b.N)
+		}
+	}
+
+	n, err := publisher.Count(b.Context())
+	if err != nil {
+		b.Errorf("%s : while counting messages in queue", err)
+	}
+	b.Logf("We managed to publish %v messages", n)
+
+	err = publisher.Purge(b.Context())
+	if err != nil {
+		b.Errorf("%s : while purging benchmark queue", err)
+	}
+}
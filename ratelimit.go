@@ -0,0 +1,193 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RateLimit limits number of tasks taken from queue by all its consumers together
+type RateLimit struct {
+	// Rate is number of tasks allowed per period, zero disables rate limiting
+	Rate int
+	// Per is period of rate, one second, if zero
+	Per time.Duration
+	// Burst is number of tasks, that can be taken at once after queue was idle, one, if zero
+	Burst int
+}
+
+// interval returns duration between tasks allowed by limit
+func (l RateLimit) interval() time.Duration {
+	per := l.Per
+	if per <= 0 {
+		per = time.Second
+	}
+	return time.Duration(math.Ceil(float64(per) / float64(l.Rate)))
+}
+
+// burst returns number of tasks, that can be taken at once
+func (l RateLimit) burst() int {
+	return max(l.Burst, 1)
+}
+
+// LimiterState is state of rate limiter of queue shared by all consumers
+type LimiterState struct {
+	// Limit is rate limit, which was applied by last consumer, that took task from queue
+	Limit RateLimit
+	// Available is number of tasks, that can be taken from queue right now
+	Available int
+	// NextAt is time, when next task can be taken from queue
+	NextAt time.Time
+}
+
+// limitedReserveScript implements generic cell rate algorithm. It reserves task like reserveScript, if there is any task
+// in lists of priority levels and theoretical arrival time of next task, stored in microseconds, allows it,
+// so permission of limiter is spent only, when task is reserved. Rate limiter is passed in the last of KEYS.
+// It returns 1 and task reserved, 0 and -1, if queue is empty, or 0 and number of microseconds to wait,
+// if rate is exceeded. Time of redis server is used, so clocks of consumers do not matter.
+var limitedReserveScript = redis.NewScript(reserveLua + `
+local n = #KEYS - 2
+local limiter = KEYS[#KEYS]
+local empty = true
+for i = 1, n do
+	if redis.call('LLEN', KEYS[i + 1]) > 0 then
+		empty = false
+		break
+	end
+end
+if empty then
+	return {0, -1}
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local tat = tonumber(redis.call('HGET', limiter, 'tat') or '0')
+if tat < now then
+	tat = now
+end
+local allowAt = tat + interval - interval * burst
+if now < allowAt then
+	return {0, allowAt - now}
+end
+local raw = reserve(n, {unpack(ARGV, 5)})
+if not raw then
+	return {0, -1}
+end
+redis.call('HSET', limiter,
+	'tat', string.format('%.0f', tat + interval),
+	'interval', ARGV[1], 'burst', ARGV[2], 'rate', ARGV[3], 'per', ARGV[4])
+return {1, raw}
+`)
+
+// SetRateLimit limits number of tasks taken from queue by all consumers, that use the same limit, together.
+// Limiter state is stored in redis, so limit should be the same for all consumers of queue.
+// Zero rate disables rate limiting.
+func (rq *RedisQueue) SetRateLimit(limit RateLimit) {
+	rq.rateLimit = limit
+}
+
+// reserveLimited reserves task like reserveScript, if rate limiter of queue allows it. If rate is exceeded,
+// it returns duration to wait before next task is allowed.
+func (rq *RedisQueue) reserveLimited(ctx context.Context) (raw string, found bool, wait time.Duration, err error) {
+	limit := rq.rateLimit
+	keys := append([]string{rq.processingKey(rq.id)}, rq.levelKeys()...)
+	args := append([]any{limit.interval().Microseconds(), limit.burst(), limit.Rate, limit.Per.Milliseconds()},
+		rq.reserveArgs()...)
+	res, err := limitedReserveScript.Run(ctx, rq.client, append(keys, rq.key("ratelimit")), args...).Slice()
+	if err != nil {
+		return
+	}
+	if len(res) != 2 {
+		return "", false, 0, fmt.Errorf("unexpected reply %v of rate limiter", res)
+	}
+	switch reply := res[1].(type) {
+	case string:
+		return reply, true, 0, nil
+	case int64:
+		return "", false, time.Duration(max(reply, 0)) * time.Microsecond, nil
+	default:
+		return "", false, 0, fmt.Errorf("unexpected reply %v of rate limiter", res)
+	}
+}
+
+// wakeAfter makes consumer try to take next task after delay provided
+func (rq *RedisQueue) wakeAfter(delay time.Duration) {
+	wake := rq.wake
+	if wake == nil {
+		return
+	}
+	notify := func() {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	if delay <= 0 {
+		notify()
+		return
+	}
+	time.AfterFunc(delay, notify)
+}
+
+// GetLimiterState returns state of rate limiter of queue shared by all its consumers
+func (rq *RedisQueue) GetLimiterState(initialCtx context.Context) (state LimiterState, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.GetLimiterState",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	now, err := rq.client.Time(ctx).Result()
+	if err != nil {
+		return
+	}
+	fields, err := rq.client.HGetAll(ctx, rq.key("ratelimit")).Result()
+	if err != nil {
+		return
+	}
+	if len(fields) == 0 {
+		// limiter was never used
+		return LimiterState{NextAt: now}, nil
+	}
+	parsed := make(map[string]int64, len(fields))
+	for k, v := range fields {
+		parsed[k], err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return state, fmt.Errorf("%w : while parsing field %s of rate limiter", err, k)
+		}
+	}
+	state.Limit = RateLimit{
+		Rate:  int(parsed["rate"]),
+		Per:   time.Duration(parsed["per"]) * time.Millisecond,
+		Burst: int(parsed["burst"]),
+	}
+	interval := parsed["interval"]
+	if interval <= 0 {
+		return state, fmt.Errorf("wrong interval %v of rate limiter", interval)
+	}
+	tat := max(parsed["tat"], now.UnixMicro())
+	allowAt := tat + interval - interval*int64(state.Limit.burst())
+	if allowAt > now.UnixMicro() {
+		state.NextAt = time.UnixMicro(allowAt)
+		return
+	}
+	state.NextAt = now
+	state.Available = int(min((now.UnixMicro()-allowAt)/interval+1, int64(state.Limit.burst())))
+	span.SetAttributes(attribute.Int("available", state.Available))
+	return
+}
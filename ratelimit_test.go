@@ -0,0 +1,145 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_RateLimitBurst(t *testing.T) {
+	const burst = 3
+	rq, err := New(t.Context(), "testRateLimitBurst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.key("ratelimit"), rq.processingKey(rq.id)).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	state, err := rq.GetLimiterState(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if state.Limit.Rate != 0 {
+		t.Errorf("unused limiter has rate %v", state.Limit.Rate)
+	}
+	rq.SetRateLimit(RateLimit{Rate: 1, Per: time.Minute, Burst: burst})
+	// empty queue does not consume tokens
+	_, found, err := rq.reserve(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if found {
+		t.Errorf("task is reserved from empty queue")
+	}
+	for i := 0; i < burst+2; i++ {
+		_, err = rq.Publish(t.Context(), "limited task")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var reserved int
+	for i := 0; i < burst+2; i++ {
+		_, found, err = rq.reserve(t.Context())
+		if err != nil {
+			t.Error(err)
+		}
+		if found {
+			reserved++
+		}
+	}
+	if reserved != burst {
+		t.Errorf("wrong number of tasks %v reserved instead of %v", reserved, burst)
+	}
+	state, err = rq.GetLimiterState(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if state.Limit.Rate != 1 || state.Limit.Per != time.Minute || state.Limit.Burst != burst {
+		t.Errorf("wrong limit %v", state.Limit)
+	}
+	if state.Available != 0 {
+		t.Errorf("wrong number of available tasks %v", state.Available)
+	}
+	if time.Until(state.NextAt) < 50*time.Second {
+		t.Errorf("wrong time of next task %s", state.NextAt)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.key("ratelimit"), rq.processingKey(rq.id)).Err()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisQueue_RateLimitConsumers(t *testing.T) {
+	const rate = 10
+	const tasks = 30
+	const window = time.Second
+	publisher, err := New(t.Context(), "testRateLimitConsumers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = publisher.client.Del(t.Context(), publisher.key("ratelimit")).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < tasks; i++ {
+		_, err = publisher.Publish(t.Context(), "call third party api")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), window)
+	defer cancel()
+	var processed atomic.Int64
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Go(func() {
+			rq, errC := New(t.Context(), "testRateLimitConsumers")
+			if errC != nil {
+				t.Error(errC)
+				return
+			}
+			defer rq.Close()
+			rq.SetHeartbeat(100 * time.Millisecond)
+			rq.SetRateLimit(RateLimit{Rate: rate, Per: time.Second})
+			errC = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				processed.Add(1)
+				return nil
+			}, 5)
+			if errC != nil && !errors.Is(errC, context.Canceled) && !errors.Is(errC, context.DeadlineExceeded) {
+				t.Error(errC)
+			}
+		})
+	}
+	wg.Wait()
+	n := processed.Load()
+	// one task at start, and rate tasks per second after it
+	if n > rate+1 {
+		t.Errorf("rate limit is exceeded: %v tasks processed in %s", n, window)
+	}
+	if n < rate/2 {
+		t.Errorf("too few tasks processed: %v in %s", n, window)
+	}
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
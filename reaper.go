@@ -0,0 +1,89 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PresenceTimeout is duration after which consumer, that have not reported its presence, is considered dead
+const PresenceTimeout = 10 * time.Second
+
+// reapScript removes dead consumer from list of consumers and returns tasks from its in-flight list
+// back to the head of their priority levels. If consumer reported its presence after it was found dead, or some of its
+// workers extended lease of task beyond current time, it is left intact.
+var reapScript = redis.NewScript(levelKeyLua + `
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) > tonumber(ARGV[2]) then
+	return -1
+end
+local lease = redis.call('ZSCORE', KEYS[3], ARGV[1])
+if lease and tonumber(lease) > tonumber(ARGV[4]) then
+	return -1
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+local n = 0
+local raw = redis.call('RPOP', KEYS[2])
+while raw do
+	redis.call('LPUSH', levelKey(raw, 4), raw)
+	n = n + 1
+	raw = redis.call('RPOP', KEYS[2])
+end
+if n > 0 then
+	redis.call('PUBLISH', ARGV[3], '1')
+end
+return n
+`)
+
+// Reap finds consumers of this queue, that have not reported their presence for PresenceTimeout,
+// returns unfinished tasks from their in-flight lists back to queue and removes them from list of consumers.
+// Consumers, which workers extended lease of task via Lease.Extend, are not reaped until lease is expired.
+// It is called periodically by every running consumer, but it can be called by anybody else too.
+func (rq *RedisQueue) Reap(initialCtx context.Context) (requeued int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Reap",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	deadline := fmt.Sprint(time.Now().Add(-PresenceTimeout).Unix())
+	dead, err := rq.client.ZRangeByScore(ctx, rq.key("consumers"),
+		&redis.ZRangeBy{Min: "-inf", Max: deadline},
+	).Result()
+	if err != nil {
+		return
+	}
+	var n int64
+	for _, consumerID := range dead {
+		n, err = reapScript.Run(ctx, rq.client,
+			append([]string{rq.key("consumers"), rq.processingKey(consumerID), rq.key("leases")}, rq.levelKeys()...),
+			consumerID, deadline, ChannelPrefix+rq.name, time.Now().Unix(),
+		).Int64()
+		if err != nil {
+			return
+		}
+		if n < 0 {
+			continue
+		}
+		span.AddEvent("dead consumer is reaped", trace.WithAttributes(
+			attribute.String("consumer.id", consumerID),
+			attribute.Int64("requeued", n),
+		))
+		requeued += n
+	}
+	span.SetAttributes(attribute.Int("n_dead_consumers", len(dead)), attribute.Int64("requeued", requeued))
+	return
+}
@@ -0,0 +1,161 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisQueue_Reap(t *testing.T) {
+	rq, err := New(t.Context(), "testReaper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	const deadConsumer = "crashedPod/testReaper/1"
+	const liveConsumer = "livePod/testReaper/2"
+
+	err = rq.client.ZAdd(t.Context(), rq.key("consumers"),
+		redis.Z{Score: float64(time.Now().Add(-time.Minute).Unix()), Member: deadConsumer},
+		redis.Z{Score: float64(time.Now().Unix()), Member: liveConsumer},
+	).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.RPush(t.Context(), rq.processingKey(deadConsumer), "lost 1", "lost 2").Err()
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.RPush(t.Context(), rq.processingKey(liveConsumer), "being processed").Err()
+	if err != nil {
+		t.Error(err)
+	}
+
+	requeued, err := rq.Reap(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if requeued != 2 {
+		t.Errorf("wrong number of requeued tasks: %v", requeued)
+	}
+	payload, found, err := rq.GetTask(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if !found || payload != "lost 1" {
+		t.Errorf("wrong first requeued task %s", payload)
+	}
+	consumers, err := rq.ListConsumers(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if _, found = consumers[liveConsumer]; !found {
+		t.Errorf("live consumer %s is reaped", liveConsumer)
+	}
+	stale, err := rq.client.ZScore(t.Context(), rq.key("consumers"), deadConsumer).Result()
+	if err != redis.Nil {
+		t.Errorf("dead consumer is not removed, score %v, error %v", stale, err)
+	}
+	inFlight, err := rq.client.LLen(t.Context(), rq.processingKey(liveConsumer)).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight != 1 {
+		t.Errorf("tasks of live consumer are touched")
+	}
+
+	err = rq.client.Del(t.Context(), rq.key("consumers"), rq.processingKey(liveConsumer)).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisQueue_ReapLongQueue(t *testing.T) {
+	t.Parallel()
+	const testSendLimit = 1100
+	consumer, err := New(t.Context(), "testReaperLongQueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	err = consumer.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	peer, err := New(t.Context(), "testReaperLongQueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	mu := sync.Mutex{}
+	processed := make(map[string]int)
+	cc, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		errC := consumer.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			processed[payload]++
+			mu.Unlock()
+			return nil
+		}, 2)
+		if errC != nil && !errors.Is(errC, context.Canceled) {
+			t.Error(errC)
+		}
+	})
+	time.Sleep(100 * time.Millisecond)
+	// every task is published with its own notification
+	for i := 0; i < testSendLimit; i++ {
+		_, err = peer.Publish(t.Context(), fmt.Sprintf("task %v", i))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	time.Sleep(PresenceTimeout + 2*time.Second)
+
+	inFlight, err := peer.client.LLen(t.Context(), peer.processingKey(consumer.GetID())).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if inFlight > 3 {
+		t.Errorf("busy consumer holds %v tasks", inFlight)
+	}
+	requeued, err := peer.Reap(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if requeued != 0 {
+		t.Errorf("%v tasks of busy consumer are reaped", requeued)
+	}
+	cancel()
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) == 0 {
+		t.Errorf("nothing is processed")
+	}
+	for payload, n := range processed {
+		if n > 1 {
+			t.Errorf("task %s is processed %v times", payload, n)
+		}
+	}
+	err = peer.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
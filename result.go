@@ -0,0 +1,330 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrResultNotFound is returned by Result, when task is not finished yet, or its result is expired
+var ErrResultNotFound = fmt.Errorf("result of task is not found")
+
+// ResultStatus shows, how task is finished
+type ResultStatus string
+
+const (
+	// ResultSucceeded means worker processed task successfully
+	ResultSucceeded ResultStatus = "succeeded"
+	// ResultFailed means task has run out of attempts or panics and was moved to dead letter queue
+	ResultFailed ResultStatus = "failed"
+	// ResultCanceled means task was canceled by Cancel
+	ResultCanceled ResultStatus = "canceled"
+	// ResultExpired means task waited in queue longer than its time to live
+	ResultExpired ResultStatus = "expired"
+)
+
+// TaskResult is outcome of task stored in redis under its id
+type TaskResult struct {
+	TaskID     string       `json:"id"`
+	Status     ResultStatus `json:"status"`
+	Value      string       `json:"value,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+// outcome returns value of task finished successfully, or TaskError
+func (r TaskResult) outcome() (value string, err error) {
+	if r.Status != ResultSucceeded {
+		return "", &TaskError{TaskID: r.TaskID, Status: r.Status, Message: r.Error}
+	}
+	return r.Value, nil
+}
+
+// TaskError is returned by Result and Wait, when task is finished unsuccessfully
+type TaskError struct {
+	TaskID  string
+	Status  ResultStatus
+	Message string
+}
+
+// Error returns text of error
+func (e *TaskError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("task %s is %s", e.TaskID, e.Status)
+	}
+	return fmt.Sprintf("task %s is %s : %s", e.TaskID, e.Status, e.Message)
+}
+
+// Unwrap allows to check for canceled and expired tasks via errors.Is
+func (e *TaskError) Unwrap() error {
+	switch e.Status {
+	case ResultCanceled:
+		return ErrTaskCanceled
+	case ResultExpired:
+		return ErrTaskExpired
+	default:
+		return nil
+	}
+}
+
+// ResultWorkerFunc is WorkerFunc, that returns result of task, so producer can receive it via Wait
+type ResultWorkerFunc func(ctx context.Context, payload string, indx int) (result string, err error)
+
+// resultHolderKey is key of context value, that receives result of task returned by worker
+type resultHolderKey struct{}
+
+// resultHolder keeps result of task returned by worker, until task is acknowledged
+type resultHolder struct {
+	value string
+}
+
+// WithResult converts worker returning result into WorkerFunc, so it can be used by ConsumeConcurrently
+// and MultiConsumer. Results are stored only if result TTL is set by SetResultTTL, and they are always
+// replied to callers of Call.
+func WithResult(worker ResultWorkerFunc) WorkerFunc {
+	return func(ctx context.Context, payload string, indx int) error {
+		result, err := worker(ctx, payload, indx)
+		if err != nil {
+			return err
+		}
+		holder, ok := ctx.Value(resultHolderKey{}).(*resultHolder)
+		if ok {
+			holder.value = result
+		}
+		return nil
+	}
+}
+
+// SetResultTTL enables storing of results of tasks finished by this consumer, so producers can receive them
+// via Result and Wait. Results are kept for ttl provided, and zero ttl disables storing them.
+// Results of tasks canceled by Cancel before they reached consumer are stored by queue, which canceled them.
+func (rq *RedisQueue) SetResultTTL(ttl time.Duration) {
+	rq.resultTTL = ttl
+}
+
+// resultKey returns name of key, where result of task is stored. Notification about result is published
+// to channel with the same name.
+func (rq *RedisQueue) resultKey(taskID string) string {
+	return fmt.Sprintf("%s/%s", rq.key("result"), taskID)
+}
+
+// recordAttempts limits number of attempts to record outcome of task, when state of chain or workflow,
+// which task belongs to, is changed concurrently
+const recordAttempts = 16
+
+// taskLinks is state of chain, group and workflow, which task belongs to, loaded before outcome of task is recorded
+type taskLinks struct {
+	chain    *Chain
+	group    *groupLink
+	workflow *WorkflowRun
+}
+
+// loadLinks loads state of chain, group and workflow, which task belongs to
+func (rq *RedisQueue) loadLinks(ctx context.Context, c redis.Cmdable, task Task) (links taskLinks, err error) {
+	links.chain, err = rq.loadChain(ctx, c, task)
+	if err != nil {
+		return
+	}
+	links.group, err = rq.loadGroup(ctx, c, task)
+	if err != nil {
+		return
+	}
+	links.workflow, err = rq.loadWorkflowOf(ctx, c, task)
+	return
+}
+
+// watchedKeys returns keys of state, which is changed, when outcome of task is recorded
+func watchedKeys(task Task) (keys []string) {
+	if chainID := task.Headers[HeaderChain]; chainID != "" {
+		keys = append(keys, chainKey(chainID))
+	}
+	if runID := task.Headers[HeaderWorkflow]; runID != "" {
+		keys = append(keys, workflowKey(runID))
+	}
+	return
+}
+
+// recordOutcome records outcome of task in one transaction with commands added by before. If task is step of chain
+// or node of workflow, their state is watched, and transaction is retried, if state is changed concurrently.
+func (rq *RedisQueue) recordOutcome(ctx context.Context, task Task, result TaskResult, before func(pipe redis.Pipeliner)) (err error) {
+	apply := func(c redis.Cmdable, pipelined func(context.Context, func(redis.Pipeliner) error) ([]redis.Cmder, error)) error {
+		links, errL := rq.loadLinks(ctx, c, task)
+		if errL != nil {
+			return errL
+		}
+		_, errL = pipelined(ctx, func(pipe redis.Pipeliner) error {
+			if before != nil {
+				before(pipe)
+			}
+			return rq.record(ctx, pipe, task, result, links)
+		})
+		return errL
+	}
+	keys := watchedKeys(task)
+	if len(keys) == 0 {
+		return apply(rq.client, rq.client.TxPipelined)
+	}
+	for range recordAttempts {
+		err = rq.client.Watch(ctx, func(tx *redis.Tx) error {
+			return apply(tx, tx.TxPipelined)
+		}, keys...)
+		if err != redis.TxFailedErr {
+			return
+		}
+	}
+	return fmt.Errorf("%w : while recording outcome of task %s", err, task.ID)
+}
+
+// record stores result of task, if results are enabled, replies to caller, if task is made by Call,
+// moves chain, if task is step of chain, counts finished member of group and moves workflow, if task is its node
+func (rq *RedisQueue) record(ctx context.Context, pipe redis.Pipeliner, task Task, result TaskResult, links taskLinks) (err error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if rq.resultTTL > 0 {
+		pipe.Set(ctx, rq.resultKey(task.ID), data, rq.resultTTL)
+		pipe.Publish(ctx, rq.resultKey(task.ID), "1")
+	}
+	replyTo := task.Headers[HeaderReplyTo]
+	if replyTo != "" && !task.ExpiresAt.IsZero() {
+		ttl := time.Until(task.ExpiresAt)
+		if ttl > 0 {
+			// nobody waits for reply after deadline of caller
+			replyScript.Eval(ctx, pipe, []string{replyTo}, data, ttl.Milliseconds())
+		}
+	}
+	if links.group != nil {
+		rq.recordGroup(ctx, pipe, links.group, task, data, result)
+	}
+	if links.workflow != nil {
+		err = rq.recordWorkflow(ctx, pipe, links.workflow, task, result)
+		if err != nil {
+			return
+		}
+	}
+	if links.chain != nil {
+		return rq.recordChain(ctx, pipe, links.chain, task, result)
+	}
+	return nil
+}
+
+// recorded returns true, if outcome of task should be recorded
+func (rq *RedisQueue) recorded(task Task) bool {
+	return task.ID != "" && (rq.resultTTL > 0 || task.Headers[HeaderReplyTo] != "" ||
+		task.Headers[HeaderChain] != "" || task.Headers[HeaderGroup] != "" || task.Headers[HeaderWorkflow] != "")
+}
+
+// finish records outcome of task with status provided
+func (rq *RedisQueue) finish(ctx context.Context, task Task, status ResultStatus, reason string) (err error) {
+	if !rq.recorded(task) {
+		return nil
+	}
+	return rq.recordOutcome(ctx, task, TaskResult{
+		TaskID:     task.ID,
+		Status:     status,
+		Error:      reason,
+		FinishedAt: time.Now(),
+	}, nil)
+}
+
+// settle removes task from in-flight list of this consumer and records its outcome atomically
+func (rq *RedisQueue) settle(ctx context.Context, raw string, task Task, result TaskResult) (err error) {
+	if !rq.recorded(task) {
+		return rq.ack(ctx, raw)
+	}
+	return rq.recordOutcome(ctx, task, result, func(pipe redis.Pipeliner) {
+		pipe.LRem(ctx, rq.processingKey(rq.id), 1, raw)
+	})
+}
+
+// complete acknowledges task processed successfully and records its result
+func (rq *RedisQueue) complete(ctx context.Context, raw string, task Task, value string) (err error) {
+	return rq.settle(ctx, raw, task, TaskResult{
+		TaskID:     task.ID,
+		Status:     ResultSucceeded,
+		Value:      value,
+		FinishedAt: time.Now(),
+	})
+}
+
+// Result returns result of task with id provided, or TaskError, if task is finished unsuccessfully.
+// ErrResultNotFound is returned, if task is not finished yet.
+func (rq *RedisQueue) Result(initialCtx context.Context, taskID string) (value string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Result",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("task.id", taskID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	record, err := rq.client.Get(ctx, rq.resultKey(taskID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return "", ErrResultNotFound
+		}
+		return
+	}
+	var result TaskResult
+	err = json.Unmarshal(record, &result)
+	if err != nil {
+		return "", fmt.Errorf("%w : while parsing result of task %s", err, taskID)
+	}
+	return result.outcome()
+}
+
+// Wait blocks until task with id provided is finished, and returns its result, or TaskError,
+// if task is finished unsuccessfully. Results are available only if consumers store them, see SetResultTTL.
+func (rq *RedisQueue) Wait(initialCtx context.Context, taskID string) (value string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Wait",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("task.id", taskID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	// subscription is made before result is checked, so notification is not missed
+	subscriber := rq.client.Subscribe(ctx, rq.resultKey(taskID))
+	defer subscriber.Close()
+	_, err = subscriber.Receive(ctx)
+	if err != nil {
+		return
+	}
+	notifications := subscriber.Channel()
+	for {
+		value, err = rq.Result(ctx, taskID)
+		if err != ErrResultNotFound {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-notifications:
+		case <-time.After(rq.heartbeat):
+			// result is checked periodically, in case notification is lost
+		}
+	}
+}
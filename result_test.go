@@ -0,0 +1,102 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_Wait(t *testing.T) {
+	publisher, err := New(t.Context(), "testWait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	publisher.SetResultTTL(time.Minute)
+
+	_, err = publisher.Result(t.Context(), "unknown")
+	if !errors.Is(err, ErrResultNotFound) {
+		t.Errorf("wrong error %v for unknown task", err)
+	}
+	canceled, err := publisher.Publish(t.Context(), "canceled")
+	if err != nil {
+		t.Error(err)
+	}
+	result, err := publisher.Cancel(t.Context(), canceled)
+	if err != nil {
+		t.Error(err)
+	}
+	if result != CancelPending {
+		t.Errorf("task is %s", result)
+	}
+	_, err = publisher.Wait(t.Context(), canceled)
+	if !errors.Is(err, ErrTaskCanceled) {
+		t.Errorf("wrong error %v for canceled task", err)
+	}
+
+	succeeded, err := publisher.Publish(t.Context(), "hello")
+	if err != nil {
+		t.Error(err)
+	}
+	failed, err := publisher.Publish(t.Context(), "fail")
+	if err != nil {
+		t.Error(err)
+	}
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		consumer, errC := New(cc, "testWait")
+		if errC != nil {
+			done <- errC
+			return
+		}
+		defer consumer.Close()
+		consumer.SetResultTTL(time.Minute)
+		consumer.SetMaxAttempts(1)
+		consumer.SetHeartbeat(100 * time.Millisecond)
+		// producer starts waiting before consumer starts
+		time.Sleep(200 * time.Millisecond)
+		done <- consumer.ConsumeConcurrently(cc, WithResult(func(ctx context.Context, payload string, indx int) (string, error) {
+			if payload == "fail" {
+				return "", fmt.Errorf("something is wrong")
+			}
+			return strings.ToUpper(payload), nil
+		}), 1)
+	}()
+
+	value, err := publisher.Wait(cc, succeeded)
+	if err != nil {
+		t.Error(err)
+	}
+	if value != "HELLO" {
+		t.Errorf("wrong result %s", value)
+	}
+	_, err = publisher.Wait(cc, failed)
+	var te *TaskError
+	if !errors.As(err, &te) {
+		t.Fatalf("wrong error %v for failed task", err)
+	}
+	if te.Status != ResultFailed || te.Message != "something is wrong" || te.TaskID != failed {
+		t.Errorf("wrong error %v", te)
+	}
+	value, err = publisher.Result(t.Context(), succeeded)
+	if err != nil {
+		t.Error(err)
+	}
+	if value != "HELLO" {
+		t.Errorf("wrong result %s", value)
+	}
+	cancel()
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,116 @@
+package grq
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryPolicy decides, how long failed task should wait before next attempt to process it
+type RetryPolicy interface {
+	// Delay returns duration to wait after attempt number attempt, starting from 1, failed
+	Delay(attempt int) time.Duration
+}
+
+// FixedRetry retries failed tasks after the same Interval every time
+type FixedRetry struct {
+	Interval time.Duration
+}
+
+// Delay returns duration to wait after attempt number attempt failed
+func (fr FixedRetry) Delay(attempt int) time.Duration {
+	return fr.Interval
+}
+
+// LinearRetry retries failed tasks after Initial interval, increasing it by Step after each attempt,
+// until Max is reached. Zero Max means interval is limited only by maximal time.Duration.
+type LinearRetry struct {
+	Initial time.Duration
+	Step    time.Duration
+	Max     time.Duration
+}
+
+// Delay returns duration to wait after attempt number attempt failed
+func (lr LinearRetry) Delay(attempt int) time.Duration {
+	steps := time.Duration(max(attempt-1, 0))
+	// interval is limited by maximal duration, so it does not overflow after many attempts
+	d := time.Duration(math.MaxInt64)
+	if lr.Step <= 0 || steps <= (d-lr.Initial)/lr.Step {
+		d = lr.Initial + steps*lr.Step
+	}
+	if lr.Max > 0 && d > lr.Max {
+		return lr.Max
+	}
+	return d
+}
+
+// ExponentialRetry retries failed tasks after Initial interval, multiplying it by Multiplier after each attempt,
+// until Max is reached. Zero Max means interval is limited only by maximal time.Duration, and Multiplier less than 1
+// is treated as 2.
+// Jitter from 0 to 1 sets random part of interval, so, for example, Jitter of 0.2 means interval
+// can be up to 20% shorter, than computed one. It prevents retries of many tasks from happening simultaneously.
+type ExponentialRetry struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// Delay returns duration to wait after attempt number attempt failed
+func (er ExponentialRetry) Delay(attempt int) time.Duration {
+	if er.Initial <= 0 {
+		return 0
+	}
+	multiplier := er.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := float64(er.Initial) * math.Pow(multiplier, float64(max(attempt-1, 0)))
+	if er.Max > 0 && d > float64(er.Max) {
+		d = float64(er.Max)
+	}
+	// interval is limited by maximal duration, so it does not overflow after many attempts
+	d = min(d, math.MaxInt64)
+	if er.Jitter > 0 {
+		d -= d * min(er.Jitter, 1) * rand.Float64()
+	}
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// deferScript moves task from in-flight list to set of scheduled tasks, if it is still in in-flight list
+var deferScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[3])
+return 1
+`)
+
+// SetRetryPolicy sets policy used to delay next attempt to process task, that worker failed to process.
+// When policy is nil, which is default, failed tasks are returned to queue immediately.
+func (rq *RedisQueue) SetRetryPolicy(policy RetryPolicy) {
+	rq.retryPolicy = policy
+}
+
+// postpone replaces task from in-flight list of this consumer by its next attempt in set of scheduled tasks,
+// so it will be returned to queue after delay provided
+func (rq *RedisQueue) postpone(ctx context.Context, raw string, next Task, delay time.Duration) (err error) {
+	member, err := scheduledMember(next)
+	if err != nil {
+		return
+	}
+	err = deferScript.Run(ctx, rq.client,
+		[]string{rq.processingKey(rq.id), rq.key("scheduled")},
+		raw, time.Now().Add(delay).UnixMilli(), member,
+	).Err()
+	if err == nil {
+		rq.dueAfter(delay)
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFixedRetry_Delay(t *testing.T) {
+	policy := FixedRetry{Interval: time.Second}
+	for attempt := 1; attempt < 5; attempt++ {
+		if policy.Delay(attempt) != time.Second {
+			t.Errorf("wrong delay %s for attempt %v", policy.Delay(attempt), attempt)
+		}
+	}
+}
+
+func TestLinearRetry_Delay(t *testing.T) {
+	policy := LinearRetry{Initial: time.Second, Step: time.Second, Max: 3 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i := range expected {
+		if policy.Delay(i+1) != expected[i] {
+			t.Errorf("wrong delay %s for attempt %v instead of %s", policy.Delay(i+1), i+1, expected[i])
+		}
+	}
+	unlimited := LinearRetry{Initial: time.Second, Step: time.Hour}
+	if d := unlimited.Delay(math.MaxInt32); d != time.Duration(math.MaxInt64) {
+		t.Errorf("delay %s for attempt %v is not limited by maximal duration", d, math.MaxInt32)
+	}
+}
+
+func TestExponentialRetry_Delay(t *testing.T) {
+	policy := ExponentialRetry{Initial: time.Second, Max: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for i := range expected {
+		if policy.Delay(i+1) != expected[i] {
+			t.Errorf("wrong delay %s for attempt %v instead of %s", policy.Delay(i+1), i+1, expected[i])
+		}
+	}
+	unlimited := ExponentialRetry{Initial: time.Second, Jitter: 0.5}
+	for _, attempt := range []int{35, 41, 100, 5000} {
+		if d := unlimited.Delay(attempt); d <= 0 {
+			t.Errorf("delay %s for attempt %v overflows", d, attempt)
+		}
+	}
+	if d := (ExponentialRetry{Initial: time.Second}).Delay(41); d != time.Duration(math.MaxInt64) {
+		t.Errorf("delay %s for attempt 41 is not limited by maximal duration", d)
+	}
+	jittered := ExponentialRetry{Initial: time.Second, Multiplier: 3, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := jittered.Delay(2)
+		if d < 1500*time.Millisecond || d > 3*time.Second {
+			t.Errorf("jittered delay %s is out of range", d)
+		}
+	}
+}
+
+func TestRedisQueue_RetryPolicy(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	rq, err := New(t.Context(), "testRetryPolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+	rq.SetRetryPolicy(FixedRetry{Interval: delay})
+	_, err = rq.Publish(t.Context(), "task failing once")
+	if err != nil {
+		t.Error(err)
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var attempts []time.Time
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		attempts = append(attempts, time.Now())
+		if len(attempts) == 1 {
+			return fmt.Errorf("first attempt failed")
+		}
+		cancel()
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("wrong number of attempts %v", len(attempts))
+	}
+	if attempts[1].Sub(attempts[0]) < delay {
+		t.Errorf("task is retried too early, after %s", attempts[1].Sub(attempts[0]))
+	}
+}
@@ -0,0 +1,142 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderReplyTo is header of task made by Call, which holds name of list, where reply should be pushed.
+// Reply is TaskResult encoded as JSON, and its id is correlation id of call.
+const HeaderReplyTo = "grq-reply-to"
+
+// replyWait limits duration of one blocking call, while caller waits for replies
+const replyWait = time.Second
+
+// replyScript pushes reply to list of caller and prolongs list, so it lives until the latest deadline of caller
+var replyScript = redis.NewScript(`
+redis.call('RPUSH', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// replyKey returns name of list, where replies to calls made by this client are pushed
+func (rq *RedisQueue) replyKey() string {
+	return fmt.Sprintf("%s/%s", rq.key("reply"), rq.id)
+}
+
+// Call publishes task and waits for reply of worker processing it, like remote procedure call.
+// Task expires at deadline of context, or after timeout of queue, if context has no deadline, so it is
+// never processed, when nobody waits for reply. Reply is returned, or TaskError, if worker failed.
+// Failed calls are not retried. Responders are started by Respond, or by any consumer with worker wrapped by WithResult.
+func (rq *RedisQueue) Call(initialCtx context.Context, p any) (reply string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Call",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rq.timeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	task, err := rq.newTask(p)
+	if err != nil {
+		return
+	}
+	task.Headers = map[string]string{HeaderReplyTo: rq.replyKey()}
+	task.ExpiresAt = deadline
+	span.SetAttributes(attribute.String("task.id", task.ID))
+
+	replies := make(chan TaskResult, 1)
+	rq.callsMu.Lock()
+	rq.calls[task.ID] = replies
+	start := !rq.receiving
+	rq.receiving = true
+	rq.callsMu.Unlock()
+	defer func() {
+		rq.callsMu.Lock()
+		delete(rq.calls, task.ID)
+		rq.callsMu.Unlock()
+	}()
+	if start {
+		go rq.receiveReplies()
+	}
+
+	err = rq.push(ctx, task, false)
+	if err != nil {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-replies:
+		return result.outcome()
+	}
+}
+
+// receiveReplies passes replies from list of this client to calls waiting for them, until there are no calls left.
+// Replies to calls, that are already finished by deadline, are dropped.
+func (rq *RedisQueue) receiveReplies() {
+	for {
+		rq.callsMu.Lock()
+		if len(rq.calls) == 0 {
+			rq.receiving = false
+			rq.callsMu.Unlock()
+			return
+		}
+		rq.callsMu.Unlock()
+		res, err := rq.client.BLPop(context.Background(), replyWait, rq.replyKey()).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, redis.ErrClosed) {
+				rq.callsMu.Lock()
+				rq.receiving = false
+				rq.callsMu.Unlock()
+				return
+			}
+			// calls are finished by their deadlines, if redis is not available
+			time.Sleep(replyWait)
+			continue
+		}
+		var result TaskResult
+		if json.Unmarshal([]byte(res[1]), &result) != nil {
+			continue
+		}
+		rq.callsMu.Lock()
+		replies, ok := rq.calls[result.TaskID]
+		rq.callsMu.Unlock()
+		if ok {
+			select {
+			case replies <- result:
+			default:
+			}
+		}
+	}
+}
+
+// Respond starts consuming calls made by Call, and replies with results returned by handler.
+// It works like ConsumeConcurrently, so ordinary tasks of queue are processed by handler too.
+func (rq *RedisQueue) Respond(ctx context.Context, handler ResultWorkerFunc, concurrency int) error {
+	return rq.ConsumeConcurrently(ctx, WithResult(handler), concurrency)
+}
@@ -0,0 +1,102 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_Call(t *testing.T) {
+	caller, err := New(t.Context(), "testCall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer caller.Close()
+	err = caller.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	responder, err := New(t.Context(), "testCall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	responder.SetHeartbeat(100 * time.Millisecond)
+
+	cc, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- responder.Respond(cc, func(ctx context.Context, payload string, indx int) (string, error) {
+			switch payload {
+			case "fail":
+				return "", fmt.Errorf("something is wrong")
+			case "slow":
+				<-ctx.Done()
+				return "", ctx.Err()
+			default:
+				return strings.ToUpper(payload), nil
+			}
+		}, 3)
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Go(func() {
+			ctx, cancelCall := context.WithTimeout(cc, 5*time.Second)
+			defer cancelCall()
+			payload := fmt.Sprintf("call %v", i)
+			reply, errC := caller.Call(ctx, payload)
+			if errC != nil {
+				t.Error(errC)
+			}
+			if reply != strings.ToUpper(payload) {
+				t.Errorf("wrong reply %s to %s", reply, payload)
+			}
+		})
+	}
+	wg.Wait()
+
+	_, err = caller.Call(cc, "fail")
+	var te *TaskError
+	if !errors.As(err, &te) {
+		t.Errorf("wrong error %v of failed call", err)
+	} else if te.Status != ResultFailed || te.Message != "something is wrong" {
+		t.Errorf("wrong error %v", te)
+	}
+
+	ctx, cancelCall := context.WithTimeout(cc, 300*time.Millisecond)
+	defer cancelCall()
+	started := time.Now()
+	_, err = caller.Call(ctx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wrong error %v of slow call", err)
+	}
+	if time.Since(started) > time.Second {
+		t.Errorf("deadline of call is not honored")
+	}
+	ttl, err := caller.client.PTTL(t.Context(), caller.replyKey()).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if ttl == -1 {
+		t.Errorf("reply list of caller never expires")
+	}
+
+	cancel()
+	err = <-done
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	n, err := caller.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("%v calls are left in queue", n)
+	}
+}
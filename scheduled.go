@@ -0,0 +1,107 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scheduledMessagePrefix starts message, which notifies consumers about task published by PublishAt.
+// It ends with time, when task is due, in milliseconds.
+const scheduledMessagePrefix = "grq:scheduled:"
+
+// promoteScript moves scheduled tasks, that are due, to the tail of their priority levels, and notifies consumers about
+// every task moved. Members of set of scheduled tasks are prefixed by task id and colon to allow duplicate payloads.
+// It returns number of tasks moved and time of the earliest task left in milliseconds, or -1, if no tasks are left.
+var promoteScript = redis.NewScript(levelKeyLua + `
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call('ZREM', KEYS[1], member)
+	local colon = string.find(member, ':', 1, true)
+	if colon then
+		member = string.sub(member, colon + 1)
+	end
+	redis.call('RPUSH', levelKey(member, 2), member)
+	redis.call('PUBLISH', ARGV[3], '1')
+end
+local earliest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #earliest == 0 then
+	return {#due, -1}
+end
+return {#due, tonumber(earliest[2])}
+`)
+
+// promoteBatchSize limits number of scheduled tasks moved to queue in one call
+const promoteBatchSize = 100
+
+// scheduledMember makes member of set of scheduled tasks from task provided
+func scheduledMember(task Task) (member string, err error) {
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	return fmt.Sprintf("%s:%s", task.ID, raw), nil
+}
+
+// promote moves scheduled tasks, that are due, to queue, and makes consumer repeat it, when the earliest
+// of tasks left is due
+func (rq *RedisQueue) promote(ctx context.Context) (n int64, err error) {
+	res, err := promoteScript.Run(ctx, rq.client,
+		append([]string{rq.key("scheduled")}, rq.levelKeys()...),
+		time.Now().UnixMilli(), promoteBatchSize, ChannelPrefix+rq.name,
+	).Int64Slice()
+	if err != nil {
+		return
+	}
+	if len(res) != 2 {
+		return 0, fmt.Errorf("unexpected reply %v of promote script", res)
+	}
+	if res[1] >= 0 {
+		rq.dueAfter(time.Until(time.UnixMilli(res[1])))
+	}
+	return res[0], nil
+}
+
+// dueAfter makes consumer move scheduled tasks to queue after delay provided, unless it is going to do it earlier
+func (rq *RedisQueue) dueAfter(delay time.Duration) {
+	due := rq.due
+	if due == nil {
+		return
+	}
+	at := time.Now().Add(delay)
+	rq.dueMu.Lock()
+	defer rq.dueMu.Unlock()
+	if rq.dueTimer != nil {
+		if !rq.dueAt.After(at) {
+			return
+		}
+		rq.dueTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(max(delay, 0), func() {
+		rq.dueMu.Lock()
+		if rq.dueTimer == timer {
+			rq.dueTimer = nil
+		}
+		rq.dueMu.Unlock()
+		select {
+		case due <- struct{}{}:
+		default:
+		}
+	})
+	rq.dueTimer = timer
+	rq.dueAt = at
+}
+
+// scheduled arms timer of consumer by message about task published by PublishAt
+func (rq *RedisQueue) scheduled(message string) {
+	ms, err := strconv.ParseInt(strings.TrimPrefix(message, scheduledMessagePrefix), 10, 64)
+	if err != nil {
+		return
+	}
+	rq.dueAfter(time.Until(time.UnixMilli(ms)))
+}
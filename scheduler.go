@@ -0,0 +1,353 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultLeaderLease is duration of leader lease of Scheduler. If leader does not renew it in time,
+// any other scheduler with the same name can become leader.
+const DefaultLeaderLease = 15 * time.Second
+
+// fireRetry is delay, after which scheduler retries to publish task, that failed to be published,
+// or to renew leader lease, that failed to be renewed
+const fireRetry = 250 * time.Millisecond
+
+// cronParser parses cron expressions with optional seconds field, descriptors like @hourly,
+// and time zone set by CRON_TZ= or TZ= prefix
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// PayloadFactory makes payload of periodic task, that is due at moment provided
+type PayloadFactory func(ctx context.Context, at time.Time) (any, error)
+
+// Job depicts state of periodic task registered in Scheduler
+type Job struct {
+	// Name is unique name of job in scheduler
+	Name string
+	// Spec is cron expression
+	Spec string
+	// Queue is name of queue, where tasks are published
+	Queue string
+	// LastRun is time, when task was published last time, it is zero, if job was never executed
+	LastRun time.Time
+	// NextRun is time, when task will be published next time
+	NextRun time.Time
+}
+
+type cronJob struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	queue    *RedisQueue
+	factory  PayloadFactory
+	next     time.Time
+}
+
+// Scheduler publishes periodic tasks to queues according to cron expressions.
+// Schedulers with the same name can be started in many processes, but only one of them,
+// that holds leader lease in redis, publishes tasks, so each task is published exactly once per tick.
+// If task fails to be published, it is retried, until next tick of its job is due.
+type Scheduler struct {
+	name     string
+	id       string
+	client   *redis.Client
+	lease    time.Duration
+	location *time.Location
+	jobs     []*cronJob
+	isLeader atomic.Bool
+}
+
+// renewScript prolongs leader lease, if it is held by scheduler provided
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript releases leader lease, if it is held by scheduler provided
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// claimScript publishes task of job and records that job is executed on tick provided, unless it was already
+// executed on this tick, so task is never published twice or lost between claim and publishing
+var claimScript = redis.NewScript(`
+local last = redis.call('HGET', KEYS[1], ARGV[1])
+if last and tonumber(last) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('RPUSH', KEYS[3], ARGV[4])
+redis.call('PUBLISH', ARGV[5], '1')
+return 1
+`)
+
+// NewScheduler creates scheduler with name provided, that keeps its leader lease and state of jobs
+// in redis database used by queue provided
+func NewScheduler(name string, rq *RedisQueue) (s *Scheduler, err error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return
+	}
+	id, err := getRandomID()
+	if err != nil {
+		return
+	}
+	return &Scheduler{
+		name:     name,
+		id:       fmt.Sprintf("%s/%s/%s/%v", hostname, name, id, os.Getpid()),
+		client:   rq.client,
+		lease:    DefaultLeaderLease,
+		location: time.Local,
+	}, nil
+}
+
+// GetID returns scheduler id
+func (s *Scheduler) GetID() string {
+	return s.id
+}
+
+// SetLeaderLease sets duration of leader lease
+func (s *Scheduler) SetLeaderLease(lease time.Duration) {
+	s.lease = lease
+}
+
+// SetLocation sets time zone used for cron expressions without CRON_TZ= prefix
+func (s *Scheduler) SetLocation(location *time.Location) {
+	s.location = location
+}
+
+// IsLeader returns true, if this scheduler is publishing tasks now
+func (s *Scheduler) IsLeader() bool {
+	return s.isLeader.Load()
+}
+
+// Register registers job with unique name, that publishes task made by factory to queue provided
+// according to cron expression spec. Expression can have optional seconds field, and can be prefixed
+// by time zone, like `CRON_TZ=Europe/Moscow 0 30 3 * * *`. Jobs should be registered before scheduler is started.
+func (s *Scheduler) Register(name, spec string, queue *RedisQueue, factory PayloadFactory) (err error) {
+	for i := range s.jobs {
+		if s.jobs[i].name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("%w : while parsing cron expression of job %s", err, name)
+	}
+	s.jobs = append(s.jobs, &cronJob{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		queue:    queue,
+		factory:  factory,
+	})
+	return nil
+}
+
+func (s *Scheduler) leaseKey() string {
+	return fmt.Sprintf("%sscheduler_%s", ChannelPrefix, s.name)
+}
+
+func (s *Scheduler) lastRunKey() string {
+	return fmt.Sprintf("%sscheduler_%s_last", ChannelPrefix, s.name)
+}
+
+func (s *Scheduler) nextRunKey() string {
+	return fmt.Sprintf("%sscheduler_%s_next", ChannelPrefix, s.name)
+}
+
+// campaign renews leader lease held by this scheduler, or tries to acquire it
+func (s *Scheduler) campaign(initialCtx context.Context) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Scheduler.campaign",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("scheduler", s.name),
+			attribute.String("scheduler.id", s.id),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Bool("leader", s.isLeader.Load()))
+		span.End()
+	}()
+	if s.isLeader.Load() {
+		renewed, errR := renewScript.Run(ctx, s.client, []string{s.leaseKey()}, s.id, s.lease.Milliseconds()).Int64()
+		if errR != nil {
+			s.isLeader.Store(false)
+			return errR
+		}
+		if renewed == 1 {
+			return nil
+		}
+	}
+	acquired, err := s.client.SetNX(ctx, s.leaseKey(), s.id, s.lease).Result()
+	s.isLeader.Store(acquired)
+	return
+}
+
+// fire publishes task of job due at moment provided, if it was not published by other scheduler already
+func (s *Scheduler) fire(initialCtx context.Context, job *cronJob, at time.Time) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.Scheduler.fire",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("scheduler", s.name),
+			attribute.String("job", job.name),
+			attribute.String("queue", job.queue.GetQueueName()),
+			attribute.String("at", at.Format(time.RFC3339)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	payload, err := job.factory(ctx, at)
+	if err != nil {
+		return fmt.Errorf("%w : while making payload of job %s", err, job.name)
+	}
+	task, err := job.queue.newTask(payload)
+	if err != nil {
+		return
+	}
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	claimed, err := claimScript.Run(ctx, s.client,
+		[]string{s.lastRunKey(), s.nextRunKey(), job.queue.levelKey(task.Priority)},
+		job.name, at.UnixMilli(), job.schedule.Next(at).UnixMilli(), raw, ChannelPrefix+job.queue.name,
+	).Int64()
+	if err != nil {
+		return
+	}
+	if claimed == 0 {
+		span.AddEvent("task is already published")
+		return nil
+	}
+	span.SetAttributes(attribute.String("task.id", task.ID))
+	return
+}
+
+// Start starts publishing tasks according to jobs registered, until context is canceled.
+// Errors of redis are recorded in spans of scheduler, and they do not stop it.
+func (s *Scheduler) Start(ctx context.Context) (err error) {
+	if len(s.jobs) == 0 {
+		return fmt.Errorf("no jobs are registered in scheduler %s", s.name)
+	}
+	now := time.Now().In(s.location)
+	for _, job := range s.jobs {
+		job.next = job.schedule.Next(now)
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx2, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.lease)
+			err = releaseScript.Run(ctx2, s.client, []string{s.leaseKey()}, s.id).Err()
+			cancel()
+			s.isLeader.Store(false)
+			return err
+
+		case <-timer.C:
+			wait := s.lease / 3
+			if s.campaign(ctx) != nil {
+				// scheduler is not leader, until lease is renewed
+				wait = min(wait, fireRetry)
+			}
+			now = time.Now().In(s.location)
+			for _, job := range s.jobs {
+				if !job.next.After(now) {
+					if s.isLeader.Load() && s.fire(ctx, job, job.next) != nil && job.schedule.Next(job.next).After(now) {
+						// tick is retried, until next tick of job is due
+						wait = min(wait, fireRetry)
+						continue
+					}
+					job.next = job.schedule.Next(now)
+				}
+				wait = min(wait, job.next.Sub(now))
+			}
+			timer.Reset(wait)
+		}
+	}
+}
+
+// Jobs returns jobs registered in scheduler with times of their last and next runs
+func (s *Scheduler) Jobs(ctx context.Context) (jobs []Job, err error) {
+	var last, next *redis.MapStringStringCmd
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		last = pipe.HGetAll(ctx, s.lastRunKey())
+		next = pipe.HGetAll(ctx, s.nextRunKey())
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	now := time.Now().In(s.location)
+	jobs = make([]Job, len(s.jobs))
+	for i, job := range s.jobs {
+		jobs[i] = Job{
+			Name:    job.name,
+			Spec:    job.spec,
+			Queue:   job.queue.GetQueueName(),
+			NextRun: job.schedule.Next(now),
+		}
+		ms, found := last.Val()[job.name]
+		if found {
+			jobs[i].LastRun, err = parseUnixMilli(ms)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ms, found = next.Val()[job.name]
+		if found {
+			recorded, errP := parseUnixMilli(ms)
+			if errP != nil {
+				return nil, errP
+			}
+			// recorded time is outdated, if leader was not running, when job was due
+			if recorded.After(now) {
+				jobs[i].NextRun = recorded
+			}
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return
+}
+
+func parseUnixMilli(raw string) (t time.Time, err error) {
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return
+	}
+	return time.UnixMilli(ms), nil
+}
@@ -0,0 +1,177 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Register(t *testing.T) {
+	rq, err := New(t.Context(), "testSchedulerRegister")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	scheduler, err := NewScheduler("testRegister", rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := func(ctx context.Context, at time.Time) (any, error) {
+		return at.Unix(), nil
+	}
+	err = scheduler.Register("seconds", "*/5 * * * * *", rq, factory)
+	if err != nil {
+		t.Error(err)
+	}
+	err = scheduler.Register("tz", "CRON_TZ=Europe/Moscow 0 30 3 * * *", rq, factory)
+	if err != nil {
+		t.Error(err)
+	}
+	err = scheduler.Register("seconds", "@hourly", rq, factory)
+	if err == nil {
+		t.Errorf("duplicate job is registered")
+	}
+	err = scheduler.Register("malformed", "this is not cron expression", rq, factory)
+	if err == nil {
+		t.Errorf("malformed cron expression is accepted")
+	}
+}
+
+func TestScheduler_Start(t *testing.T) {
+	const testSchedulerQueueName = "testScheduler"
+	const replicas = 3
+	const duration = 3500 * time.Millisecond
+
+	rq, err := New(t.Context(), testSchedulerQueueName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), "redisQueue/scheduler_testScheduler_last", "redisQueue/scheduler_testScheduler_next").Err()
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), duration)
+	defer cancel()
+	schedulers := make([]*Scheduler, replicas)
+	wg := sync.WaitGroup{}
+	for i := range schedulers {
+		schedulers[i], err = NewScheduler(testSchedulerQueueName, rq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedulers[i].SetLeaderLease(time.Second)
+		err = schedulers[i].Register("every second", "* * * * * *", rq, func(ctx context.Context, at time.Time) (any, error) {
+			return at.Format(time.RFC3339), nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		wg.Go(func() {
+			errS := schedulers[i].Start(ctx)
+			if errS != nil {
+				t.Error(errS)
+			}
+		})
+	}
+	time.Sleep(duration / 2)
+	var leaders int
+	for i := range schedulers {
+		if schedulers[i].IsLeader() {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Errorf("wrong number of leaders %v", leaders)
+	}
+	wg.Wait()
+
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n < 2 || n > 4 {
+		t.Errorf("wrong number of tasks published %v", n)
+	}
+	jobs, err := schedulers[0].Jobs(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("wrong number of jobs %v", len(jobs))
+	}
+	if jobs[0].LastRun.IsZero() {
+		t.Errorf("last run is not recorded")
+	}
+	if !jobs[0].NextRun.After(jobs[0].LastRun) {
+		t.Errorf("next run %s is before last run %s", jobs[0].NextRun, jobs[0].LastRun)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScheduler_StartRetry(t *testing.T) {
+	rq, err := New(t.Context(), "testSchedulerRetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), "redisQueue/scheduler_testSchedulerRetry_last", "redisQueue/scheduler_testSchedulerRetry_next").Err()
+	if err != nil {
+		t.Error(err)
+	}
+	scheduler, err := NewScheduler("testSchedulerRetry", rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failedAt time.Time
+	err = scheduler.Register("every second", "* * * * * *", rq, func(ctx context.Context, at time.Time) (any, error) {
+		if failedAt.IsZero() {
+			failedAt = at
+			return nil, fmt.Errorf("something is wrong")
+		}
+		return at.Format(time.RFC3339), nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 1500*time.Millisecond)
+	defer cancel()
+	err = scheduler.Start(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if failedAt.IsZero() {
+		t.Fatalf("job is never executed")
+	}
+	published, err := rq.client.LRange(t.Context(), rq.GetQueueName(), 0, -1).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	var retried bool
+	for _, raw := range published {
+		if decodeTask(raw).Payload == failedAt.Format(time.RFC3339) {
+			retried = true
+		}
+	}
+	if !retried {
+		t.Errorf("tick %s is not retried after failure", failedAt)
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
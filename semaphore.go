@@ -0,0 +1,161 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slotTTL is duration, after which slot of global concurrency is released, if consumer holding it
+// have not prolonged it. Consumers prolong their slots every time they report presence.
+const slotTTL = PresenceTimeout
+
+// acquireSlotScript occupies slot of global concurrency, if there is free one, and there is any task
+// in lists of priority levels. Slots are members of sorted set scored by time of their expiration
+// in milliseconds, so slots of dead consumers are released automatically. It returns 1, if slot is occupied,
+// 0, if all slots are busy, and -1, if queue is empty. Time of redis server is used.
+var acquireSlotScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+local empty = true
+for i = 2, #KEYS do
+	if redis.call('LLEN', KEYS[i]) > 0 then
+		empty = false
+		break
+	end
+end
+if empty then
+	return -1
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), ARGV[2])
+return 1
+`)
+
+// releaseSlotsScript frees slots of global concurrency and notifies consumers, so they can occupy them
+var releaseSlotsScript = redis.NewScript(`
+local n = 0
+for i = 2, #ARGV do
+	n = n + redis.call('ZREM', KEYS[1], ARGV[i])
+end
+if n > 0 then
+	redis.call('PUBLISH', ARGV[1], '1')
+end
+return n
+`)
+
+// refreshSlotsScript prolongs slots of global concurrency, that still exist
+var refreshSlotsScript = redis.NewScript(`
+local t = redis.call('TIME')
+local expireAt = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000) + tonumber(ARGV[1])
+for i = 2, #ARGV do
+	redis.call('ZADD', KEYS[1], 'XX', expireAt, ARGV[i])
+end
+return #ARGV - 1
+`)
+
+// SetGlobalConcurrency limits number of tasks being processed by all consumers of queue, that use the same limit,
+// together. Slot is taken only for worker, that is idle, so consumer never holds slots for tasks, that it does not process.
+// Slots of consumers, that died, are released after PresenceTimeout. Zero means concurrency is limited
+// only by ConsumeConcurrently argument of every consumer.
+func (rq *RedisQueue) SetGlobalConcurrency(n int) {
+	rq.globalConcurrency = n
+}
+
+// acquireSlot occupies slot of global concurrency for task to be reserved
+func (rq *RedisQueue) acquireSlot(ctx context.Context) (acquired bool, err error) {
+	suffix, err := getRandomID()
+	if err != nil {
+		return
+	}
+	slot := fmt.Sprintf("%s/%s", rq.id, suffix)
+	res, err := acquireSlotScript.Run(ctx, rq.client,
+		append([]string{rq.key("semaphore")}, rq.levelKeys()...),
+		rq.globalConcurrency, slot, slotTTL.Milliseconds(),
+	).Int64()
+	if err != nil || res != 1 {
+		return false, err
+	}
+	rq.slotsMu.Lock()
+	rq.slots = append(rq.slots, slot)
+	rq.slotsMu.Unlock()
+	return true, nil
+}
+
+// releaseSlot frees one of slots of global concurrency held by this consumer
+func (rq *RedisQueue) releaseSlot(ctx context.Context) (err error) {
+	rq.slotsMu.Lock()
+	if len(rq.slots) == 0 {
+		rq.slotsMu.Unlock()
+		return nil
+	}
+	slot := rq.slots[len(rq.slots)-1]
+	rq.slots = rq.slots[:len(rq.slots)-1]
+	rq.slotsMu.Unlock()
+	return releaseSlotsScript.Run(ctx, rq.client,
+		[]string{rq.key("semaphore")},
+		ChannelPrefix+rq.name, slot,
+	).Err()
+}
+
+// releaseAllSlots frees all slots of global concurrency held by this consumer
+func (rq *RedisQueue) releaseAllSlots(ctx context.Context) (err error) {
+	rq.slotsMu.Lock()
+	slots := rq.slots
+	rq.slots = nil
+	rq.slotsMu.Unlock()
+	if len(slots) == 0 {
+		return nil
+	}
+	args := []any{ChannelPrefix + rq.name}
+	for _, slot := range slots {
+		args = append(args, slot)
+	}
+	return releaseSlotsScript.Run(ctx, rq.client, []string{rq.key("semaphore")}, args...).Err()
+}
+
+// refreshSlots prolongs slots of global concurrency held by this consumer, so they are not released while
+// consumer is alive
+func (rq *RedisQueue) refreshSlots(ctx context.Context) (err error) {
+	rq.slotsMu.Lock()
+	args := []any{slotTTL.Milliseconds()}
+	for _, slot := range rq.slots {
+		args = append(args, slot)
+	}
+	rq.slotsMu.Unlock()
+	if len(args) == 1 {
+		return nil
+	}
+	return refreshSlotsScript.Run(ctx, rq.client, []string{rq.key("semaphore")}, args...).Err()
+}
+
+// CountBusySlots counts slots of global concurrency occupied by all consumers of queue
+func (rq *RedisQueue) CountBusySlots(initialCtx context.Context) (n int64, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.CountBusySlots",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("queue", rq.name)),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	now, err := rq.client.Time(ctx).Result()
+	if err != nil {
+		return
+	}
+	// slots expiring right now are already free
+	n, err = rq.client.ZCount(ctx, rq.key("semaphore"), fmt.Sprintf("(%d", now.UnixMilli()), "+inf").Result()
+	return
+}
@@ -0,0 +1,180 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisQueue_SetGlobalConcurrency(t *testing.T) {
+	const limit = 2
+	const tasks = 10
+	publisher, err := New(t.Context(), "testGlobalConcurrency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	// slot of consumer, that died long ago
+	err = publisher.client.ZAdd(t.Context(), publisher.key("semaphore"), redis.Z{
+		Score:  float64(time.Now().Add(-time.Minute).UnixMilli()),
+		Member: "crashedPod/testGlobalConcurrency/1/slot",
+	}).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < tasks; i++ {
+		_, err = publisher.Publish(t.Context(), "query database")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var running, maxRunning, processed atomic.Int64
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Go(func() {
+			rq, errC := New(t.Context(), "testGlobalConcurrency")
+			if errC != nil {
+				t.Error(errC)
+				return
+			}
+			defer rq.Close()
+			rq.SetHeartbeat(10 * time.Millisecond)
+			rq.SetGlobalConcurrency(limit)
+			errC = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				now := running.Add(1)
+				for {
+					prev := maxRunning.Load()
+					if now <= prev || maxRunning.CompareAndSwap(prev, now) {
+						break
+					}
+				}
+				busy, errS := rq.CountBusySlots(ctx)
+				if errS != nil {
+					t.Error(errS)
+				}
+				if busy > limit {
+					t.Errorf("%v slots are busy", busy)
+				}
+				time.Sleep(50 * time.Millisecond)
+				running.Add(-1)
+				if processed.Add(1) == tasks {
+					cancel()
+				}
+				return nil
+			}, 5)
+			if errC != nil && !errors.Is(errC, context.Canceled) {
+				t.Error(errC)
+			}
+		})
+	}
+	wg.Wait()
+	if processed.Load() != tasks {
+		t.Errorf("wrong number of processed tasks %v", processed.Load())
+	}
+	if maxRunning.Load() > limit {
+		t.Errorf("global concurrency is exceeded: %v tasks were processed at once", maxRunning.Load())
+	}
+	busy, err := publisher.CountBusySlots(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if busy != 0 {
+		t.Errorf("%v slots are not released by stopped consumers", busy)
+	}
+}
+
+func TestRedisQueue_SetGlobalConcurrencyIdle(t *testing.T) {
+	const limit = 4
+	const tasks = 20
+	publisher, err := New(t.Context(), "testGlobalConcurrencyIdle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	err = publisher.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var running, maxRunning, processed atomic.Int64
+	var alone atomic.Bool
+	alone.Store(true)
+	perConsumer := make([]atomic.Int64, 2)
+	wg := sync.WaitGroup{}
+	for i := range perConsumer {
+		if i > 0 {
+			// the first consumer has time to take all slots, if it reserves tasks for busy workers
+			time.Sleep(200 * time.Millisecond)
+			alone.Store(false)
+		}
+		wg.Go(func() {
+			rq, errC := New(t.Context(), "testGlobalConcurrencyIdle")
+			if errC != nil {
+				t.Error(errC)
+				return
+			}
+			defer rq.Close()
+			rq.SetGlobalConcurrency(limit)
+			errC = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				now := running.Add(1)
+				for {
+					prev := maxRunning.Load()
+					if now <= prev || maxRunning.CompareAndSwap(prev, now) {
+						break
+					}
+				}
+				if alone.Load() {
+					busy, errS := rq.CountBusySlots(ctx)
+					if errS != nil {
+						t.Error(errS)
+					}
+					if busy > 2 {
+						t.Errorf("%v slots are busy, while consumer has 2 workers", busy)
+					}
+				}
+				time.Sleep(100 * time.Millisecond)
+				running.Add(-1)
+				perConsumer[i].Add(1)
+				if processed.Add(1) == tasks {
+					cancel()
+				}
+				return nil
+			}, 1)
+			if errC != nil && !errors.Is(errC, context.Canceled) {
+				t.Error(errC)
+			}
+		})
+		if i == 0 {
+			time.Sleep(100 * time.Millisecond)
+			for j := 0; j < tasks; j++ {
+				_, err = publisher.Publish(t.Context(), "query database")
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+	wg.Wait()
+	if processed.Load() != tasks {
+		t.Errorf("wrong number of processed tasks %v", processed.Load())
+	}
+	if perConsumer[1].Load() == 0 {
+		t.Errorf("slots are held by the first consumer for tasks, that it does not process")
+	}
+	if maxRunning.Load() != limit {
+		t.Errorf("%v tasks were processed at once instead of %v", maxRunning.Load(), limit)
+	}
+}
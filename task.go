@@ -0,0 +1,114 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// envelopeVersion is version of envelope format, that is used to tell envelopes from legacy raw payloads
+const envelopeVersion = 1
+
+// envelopePrefix is how every encoded envelope starts
+const envelopePrefix = `{"grq":`
+
+// Task is envelope of payload with metadata. Tasks published by this package are stored in redis
+// as JSON objects, while tasks pushed to queue by other clients, like redis-cli, are treated as raw payloads.
+type Task struct {
+	// ID is unique task id. It is empty for raw tasks pushed by other clients
+	ID string `json:"id"`
+	// Payload is what WorkerFunc receives
+	Payload string `json:"payload"`
+	// EnqueuedAt is time, when task was published
+	EnqueuedAt time.Time `json:"enqueued_at,omitzero"`
+	// Attempt is number of current attempt to process task, starting from 1
+	Attempt int `json:"attempt"`
+	// Producer is id of RedisQueue, that published task
+	Producer string `json:"producer,omitempty"`
+	// ContentType depicts format of payload, like application/json
+	ContentType string `json:"content_type,omitempty"`
+	// Headers are free form metadata of task
+	Headers map[string]string `json:"headers,omitempty"`
+	// FirstFailedAt is time of first failed attempt to process task
+	FirstFailedAt time.Time `json:"first_failed_at,omitzero"`
+	// LastError is error returned by worker during last failed attempt
+	LastError string `json:"last_error,omitempty"`
+	// Panics is number of attempts, during which worker panicked
+	Panics int `json:"panics,omitempty"`
+	// Priority is priority level of task
+	Priority Priority `json:"priority,omitempty"`
+	// ExpiresAt is time, after which task is not passed to worker
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// envelope is how Task is stored in redis
+type envelope struct {
+	Version int `json:"grq"`
+	Task
+}
+
+// newTask makes task from payload provided, published by this queue.
+// Payload can be anything that can be stringified by fmt.Sprint
+func (rq *RedisQueue) newTask(p any) (task Task, err error) {
+	task.ID, err = getRandomID()
+	if err != nil {
+		return
+	}
+	task.Payload = fmt.Sprint(p)
+	task.EnqueuedAt = time.Now()
+	task.Attempt = 1
+	task.Producer = rq.id
+	return
+}
+
+// encode makes string representation of task stored in redis
+func (t Task) encode() (raw string, err error) {
+	data, err := json.Marshal(envelope{Version: envelopeVersion, Task: t})
+	if err != nil {
+		return
+	}
+	return string(data), nil
+}
+
+// decodeTask makes task from string stored in redis. Strings, that are not envelopes, are treated as raw payloads.
+func decodeTask(raw string) (task Task) {
+	if strings.HasPrefix(raw, envelopePrefix) {
+		var e envelope
+		if json.Unmarshal([]byte(raw), &e) == nil && e.Version > 0 {
+			if e.Attempt < 1 {
+				e.Attempt = 1
+			}
+			return e.Task
+		}
+	}
+	return Task{Payload: raw, Attempt: 1}
+}
+
+type taskContextKey struct{}
+
+type batchContextKey struct{}
+
+// withTask makes context carrying task being processed
+func withTask(ctx context.Context, task Task) context.Context {
+	return context.WithValue(ctx, taskContextKey{}, task)
+}
+
+// withBatch makes context carrying tasks of batch being processed
+func withBatch(ctx context.Context, tasks []Task) context.Context {
+	return context.WithValue(ctx, batchContextKey{}, tasks)
+}
+
+// BatchFromContext returns tasks of batch being processed by BatchWorkerFunc, which received context provided,
+// in order of their payloads
+func BatchFromContext(ctx context.Context) (tasks []Task, found bool) {
+	tasks, found = ctx.Value(batchContextKey{}).([]Task)
+	return
+}
+
+// TaskFromContext returns task being processed by WorkerFunc, which received context provided
+func TaskFromContext(ctx context.Context) (task Task, found bool) {
+	task, found = ctx.Value(taskContextKey{}).(Task)
+	return
+}
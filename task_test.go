@@ -0,0 +1,136 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTaskEncodeDecode(t *testing.T) {
+	task := Task{
+		ID:          "0123456789abcdef0123",
+		Payload:     `{"order":42}`,
+		EnqueuedAt:  time.Now().Truncate(time.Millisecond),
+		Attempt:     2,
+		Producer:    "producer",
+		ContentType: "application/json",
+		Headers:     map[string]string{"trace": "1"},
+	}
+	raw, err := task.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := decodeTask(raw)
+	if decoded.ID != task.ID || decoded.Payload != task.Payload || decoded.Attempt != task.Attempt {
+		t.Errorf("wrong task decoded %v", decoded)
+	}
+	if !decoded.EnqueuedAt.Equal(task.EnqueuedAt) {
+		t.Errorf("wrong enqueue time decoded %s", decoded.EnqueuedAt)
+	}
+	if decoded.Headers["trace"] != "1" || decoded.ContentType != "application/json" {
+		t.Errorf("wrong metadata decoded %v", decoded)
+	}
+}
+
+func TestDecodeRawTask(t *testing.T) {
+	for _, raw := range []string{"1419719", `{"order":42}`, `{"grq":broken`, ""} {
+		task := decodeTask(raw)
+		if task.Payload != raw {
+			t.Errorf("wrong payload %s decoded from %s", task.Payload, raw)
+		}
+		if task.ID != "" {
+			t.Errorf("raw task %s has id %s", raw, task.ID)
+		}
+		if task.Attempt != 1 {
+			t.Errorf("wrong attempt %v of raw task %s", task.Attempt, raw)
+		}
+	}
+}
+
+func TestRedisQueue_PublishTask(t *testing.T) {
+	rq, err := New(t.Context(), "testEnvelope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = rq.PublishTask(t.Context(), Task{ID: "wrong:id", Payload: "something"})
+	if err == nil {
+		t.Errorf("task with colon in id is published")
+	}
+	id, err := rq.PublishTask(t.Context(), Task{
+		Payload:     `{"order":42}`,
+		ContentType: "application/json",
+		Headers:     map[string]string{"tenant": "acme"},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if id == "" {
+		t.Errorf("task id is not generated")
+	}
+	// raw task, as it is pushed by redis-cli
+	err = rq.client.RPush(t.Context(), rq.GetQueueName(), "1419719").Err()
+	if err != nil {
+		t.Error(err)
+	}
+	rq.SetHeartbeat(10 * time.Millisecond)
+
+	cc, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	var rawAttempts int
+	err = rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+		task, found := TaskFromContext(ctx)
+		if !found {
+			t.Errorf("task is not found in context")
+			return nil
+		}
+		if task.Payload != payload {
+			t.Errorf("wrong payload %s in context", task.Payload)
+		}
+		switch payload {
+		case `{"order":42}`:
+			if task.ID != id {
+				t.Errorf("wrong task id %s instead of %s", task.ID, id)
+			}
+			if task.Headers["tenant"] != "acme" || task.ContentType != "application/json" {
+				t.Errorf("wrong task metadata %v", task)
+			}
+			if task.Producer != rq.GetID() {
+				t.Errorf("wrong producer %s", task.Producer)
+			}
+		case "1419719":
+			rawAttempts++
+			if task.Attempt != rawAttempts {
+				t.Errorf("wrong attempt %v of raw task", task.Attempt)
+			}
+			if rawAttempts == 1 {
+				if task.ID != "" {
+					t.Errorf("raw task has id %s", task.ID)
+				}
+				return fmt.Errorf("raw task failed")
+			}
+			if task.ID == "" {
+				t.Errorf("failed raw task is not upgraded to envelope")
+			}
+			if task.LastError != "raw task failed" {
+				t.Errorf("wrong last error %s", task.LastError)
+			}
+			cancel()
+		default:
+			t.Errorf("unexpected payload %s", payload)
+		}
+		return nil
+	}, 0)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	if rawAttempts != 2 {
+		t.Errorf("wrong number of attempts to process raw task %v", rawAttempts)
+	}
+}
@@ -0,0 +1,82 @@
+package grq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// publishUniqueScript remembers task id under deduplication key for ttl provided and pushes task to the tail
+// of queue, if deduplication key is not set yet. Id of task remembered under key and 1 is returned if task is
+// published, or id of task published earlier and 0, if it is duplicate.
+var publishUniqueScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return {redis.call('GET', KEYS[1]), 0}
+end
+redis.call('RPUSH', KEYS[2], ARGV[3])
+redis.call('PUBLISH', ARGV[4], '1')
+return {ARGV[1], 1}
+`)
+
+// uniqueKey returns name of key, that remembers task published with deduplication key provided
+func (rq *RedisQueue) uniqueKey(key string) string {
+	return fmt.Sprintf("%s/%s", rq.key("unique"), key)
+}
+
+// PublishUnique sends task to channel, only if no other task with the same deduplication key was published
+// into this queue during ttl provided. If task is duplicate, it is not published, duplicate is true,
+// and id of task published earlier is returned. Deduplication keys expire on their own after ttl.
+func (rq *RedisQueue) PublishUnique(initialCtx context.Context, key string, ttl time.Duration, p any) (id string, duplicate bool, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishUnique",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("key", key),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	if key == "" {
+		return "", false, fmt.Errorf("deduplication key is empty")
+	}
+	if ttl < time.Millisecond {
+		return "", false, fmt.Errorf("deduplication window %s is too short", ttl)
+	}
+	task, err := rq.newTask(p)
+	if err != nil {
+		return
+	}
+	raw, err := task.encode()
+	if err != nil {
+		return
+	}
+	res, err := publishUniqueScript.Run(ctx, rq.client,
+		[]string{rq.uniqueKey(key), rq.name},
+		task.ID, ttl.Milliseconds(), raw, ChannelPrefix+rq.name,
+	).Slice()
+	if err != nil {
+		return
+	}
+	if len(res) != 2 {
+		return "", false, fmt.Errorf("unexpected reply %v while publishing unique task", res)
+	}
+	id = fmt.Sprint(res[0])
+	duplicate = res[1] == int64(0)
+	span.SetAttributes(
+		attribute.String("task.id", id),
+		attribute.Bool("duplicate", duplicate),
+	)
+	return
+}
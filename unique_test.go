@@ -0,0 +1,73 @@
+package grq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PublishUnique(t *testing.T) {
+	const window = 300 * time.Millisecond
+	rq, err := New(t.Context(), "testPublishUnique")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	err = rq.client.Del(t.Context(), rq.uniqueKey("order42"), rq.uniqueKey("order43")).Err()
+	if err != nil {
+		t.Error(err)
+	}
+	id, duplicate, err := rq.PublishUnique(t.Context(), "order42", window, "charge order 42")
+	if err != nil {
+		t.Error(err)
+	}
+	if duplicate {
+		t.Errorf("first task is reported as duplicate")
+	}
+	again, duplicate, err := rq.PublishUnique(t.Context(), "order42", window, "charge order 42")
+	if err != nil {
+		t.Error(err)
+	}
+	if !duplicate {
+		t.Errorf("second task is not reported as duplicate")
+	}
+	if again != id {
+		t.Errorf("wrong id %s of duplicate instead of %s", again, id)
+	}
+	_, duplicate, err = rq.PublishUnique(t.Context(), "order43", window, "charge order 43")
+	if err != nil {
+		t.Error(err)
+	}
+	if duplicate {
+		t.Errorf("task with other key is reported as duplicate")
+	}
+	n, err := rq.Count(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("wrong number of tasks in queue %v", n)
+	}
+	time.Sleep(window + 100*time.Millisecond)
+	later, duplicate, err := rq.PublishUnique(t.Context(), "order42", window, "charge order 42")
+	if err != nil {
+		t.Error(err)
+	}
+	if duplicate {
+		t.Errorf("task is reported as duplicate after window expired")
+	}
+	if later == id {
+		t.Errorf("same id %s is returned after window expired", later)
+	}
+	_, _, err = rq.PublishUnique(t.Context(), "", window, "without key")
+	if err == nil {
+		t.Errorf("task without key is published")
+	}
+	err = rq.Purge(t.Context())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -16,3 +16,16 @@ import (
 // Returns:
 // error - If an error occurs during the processing, it returns the error; otherwise, it returns nil.
 type WorkerFunc func(ctx context.Context, payload string, indx int) error
+
+// BatchWorkerFunc represents a function signature used to process batch of tasks with the given context,
+// payloads, and index. User should implement this function and provide it to RedisQueue.ConsumeBatches.
+//
+// Parameters:
+// ctx context.Context - The context is used for handling cancellation and deadlines.
+// payloads []string - The payloads of tasks in batch, in order they were taken from queue.
+// indx int - The index of a worker who is processing the batch.
+//
+// Returns:
+// error - nil, if all tasks are processed, BatchError, if some of them failed, or any other error,
+// if whole batch failed.
+type BatchWorkerFunc func(ctx context.Context, payloads []string, indx int) error
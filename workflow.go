@@ -0,0 +1,461 @@
+package grq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HeaderWorkflow is header of task, that is node of workflow, which holds id of workflow run
+	HeaderWorkflow = "grq-workflow"
+	// HeaderWorkflowNode is header of task, that is node of workflow, which holds name of node
+	HeaderWorkflowNode = "grq-workflow-node"
+)
+
+// DefaultWorkflowTTL is how long state of finished workflow run is kept in redis
+const DefaultWorkflowTTL = 24 * time.Hour
+
+// ErrWorkflowNotFound is returned, when workflow run does not exist, or its state is expired
+var ErrWorkflowNotFound = fmt.Errorf("workflow is not found")
+
+// WorkflowStatus is state of workflow run
+type WorkflowStatus string
+
+const (
+	// WorkflowRunning means workflow has nodes to be processed
+	WorkflowRunning WorkflowStatus = "running"
+	// WorkflowSucceeded means all nodes of workflow are processed successfully
+	WorkflowSucceeded WorkflowStatus = "succeeded"
+	// WorkflowFailed means node of workflow failed or expired, so no new nodes are published, until workflow is retried
+	WorkflowFailed WorkflowStatus = "failed"
+	// WorkflowCanceled means workflow was canceled by CancelWorkflow
+	WorkflowCanceled WorkflowStatus = "canceled"
+)
+
+// NodeStatus is state of node of workflow run
+type NodeStatus string
+
+const (
+	// NodeWaiting means node waits for its dependencies to succeed
+	NodeWaiting NodeStatus = "waiting"
+	// NodePending means task of node is published, and it is not finished yet
+	NodePending NodeStatus = "pending"
+	// NodeSucceeded means task of node is processed successfully
+	NodeSucceeded = NodeStatus(ResultSucceeded)
+	// NodeFailed means task of node is moved to dead letter queue
+	NodeFailed = NodeStatus(ResultFailed)
+	// NodeCanceled means task of node is canceled
+	NodeCanceled = NodeStatus(ResultCanceled)
+	// NodeExpired means task of node is expired
+	NodeExpired = NodeStatus(ResultExpired)
+)
+
+// WorkflowNode is named task of workflow, which is published to queue provided, when all nodes
+// it depends on succeed
+type WorkflowNode struct {
+	// Name is unique name of node in workflow
+	Name string `json:"name"`
+	// Queue is name of queue, where task of node is published
+	Queue string `json:"queue"`
+	// Payload is payload of task of node
+	Payload string `json:"payload"`
+	// DependsOn is list of names of nodes, which should succeed before this node is published
+	DependsOn []string `json:"depends_on,omitempty"`
+	// TaskID is id of the latest task of node, it is set, when node is published
+	TaskID string `json:"task_id,omitempty"`
+	// Status is state of node
+	Status NodeStatus `json:"status"`
+	// Error is error of failed task of node
+	Error string `json:"error,omitempty"`
+}
+
+// WorkflowRun is state of workflow run stored in redis
+type WorkflowRun struct {
+	ID        string         `json:"id"`
+	Status    WorkflowStatus `json:"status"`
+	Nodes     []WorkflowNode `json:"nodes"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// workflowKey returns name of key, where state of workflow run is stored
+func workflowKey(runID string) string {
+	return fmt.Sprintf("%sworkflow/%s", ChannelPrefix, runID)
+}
+
+// checkWorkflow checks, that names of nodes are unique, dependencies exist and have no cycles
+func checkWorkflow(nodes []WorkflowNode) (err error) {
+	if len(nodes) == 0 {
+		return fmt.Errorf("workflow has no nodes")
+	}
+	indegree := make(map[string]int, len(nodes))
+	for i := range nodes {
+		if nodes[i].Name == "" {
+			return fmt.Errorf("node %v of workflow has no name", i)
+		}
+		if nodes[i].Queue == "" {
+			return fmt.Errorf("node %s of workflow has no queue", nodes[i].Name)
+		}
+		if _, found := indegree[nodes[i].Name]; found {
+			return fmt.Errorf("node %s of workflow is duplicated", nodes[i].Name)
+		}
+		indegree[nodes[i].Name] = len(nodes[i].DependsOn)
+	}
+	for i := range nodes {
+		for _, parent := range nodes[i].DependsOn {
+			if _, found := indegree[parent]; !found {
+				return fmt.Errorf("node %s depends on unknown node %s", nodes[i].Name, parent)
+			}
+		}
+	}
+	// nodes without dependencies are removed one by one, and nodes left form cycle
+	var ready []string
+	for name, n := range indegree {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+	removed := 0
+	for len(ready) > 0 {
+		name := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		removed++
+		for i := range nodes {
+			if slices.Contains(nodes[i].DependsOn, name) {
+				indegree[nodes[i].Name]--
+				if indegree[nodes[i].Name] == 0 {
+					ready = append(ready, nodes[i].Name)
+				}
+			}
+		}
+	}
+	if removed != len(nodes) {
+		return fmt.Errorf("dependencies of workflow have cycle")
+	}
+	return nil
+}
+
+// PublishWorkflow publishes nodes of workflow, which have no dependencies, and every other node is published
+// by consumer, which processed the last of nodes it depends on successfully. If node is moved to dead letter queue
+// or expires, workflow is failed, and no new nodes are published, until workflow is retried by RetryWorkflow.
+// Nodes can be published to different queues of the same redis server, and their consumers should be made
+// by this package. Id of workflow run is returned, and its state can be received via GetWorkflow.
+func (rq *RedisQueue) PublishWorkflow(initialCtx context.Context, nodes ...WorkflowNode) (runID string, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.PublishWorkflow",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.Int("nodes", len(nodes)),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	err = checkWorkflow(nodes)
+	if err != nil {
+		return
+	}
+	runID, err = getRandomID()
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("workflow.id", runID))
+	run := WorkflowRun{
+		ID:        runID,
+		Status:    WorkflowRunning,
+		Nodes:     slices.Clone(nodes),
+		UpdatedAt: time.Now(),
+	}
+	for i := range run.Nodes {
+		run.Nodes[i].DependsOn = slices.Clone(run.Nodes[i].DependsOn)
+		run.Nodes[i].TaskID = ""
+		run.Nodes[i].Status = NodeWaiting
+		run.Nodes[i].Error = ""
+	}
+	_, err = rq.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		_, errS := rq.startReadyNodes(ctx, pipe, &run)
+		return errS
+	})
+	return runID, err
+}
+
+// GetWorkflow returns state of workflow run with id provided
+func (rq *RedisQueue) GetWorkflow(initialCtx context.Context, runID string) (run WorkflowRun, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.GetWorkflow",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("workflow.id", runID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	found, err := loadWorkflow(ctx, rq.client, runID, &run)
+	if err != nil {
+		return
+	}
+	if !found {
+		return run, ErrWorkflowNotFound
+	}
+	return
+}
+
+// RetryWorkflow publishes again nodes of workflow run, which failed, expired or were canceled, and nodes,
+// which dependencies succeeded, while workflow was stopped. Number of nodes published is returned.
+func (rq *RedisQueue) RetryWorkflow(initialCtx context.Context, runID string) (retried int, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.RetryWorkflow",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("workflow.id", runID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.SetAttributes(attribute.Int("retried", retried))
+		span.End()
+	}()
+	err = rq.updateWorkflow(ctx, runID, func(pipe redis.Pipeliner, run *WorkflowRun) (errU error) {
+		if run.Status == WorkflowSucceeded {
+			return fmt.Errorf("workflow %s is already succeeded", runID)
+		}
+		for i := range run.Nodes {
+			switch run.Nodes[i].Status {
+			case NodeFailed, NodeExpired, NodeCanceled:
+				run.Nodes[i].Status = NodeWaiting
+				run.Nodes[i].Error = ""
+			}
+		}
+		run.Status = WorkflowRunning
+		retried, errU = rq.startReadyNodes(ctx, pipe, run)
+		return errU
+	})
+	return
+}
+
+// CancelWorkflow stops workflow run, so no new nodes are published, and cancels tasks of nodes,
+// which are published, but not finished yet, via Cancel. Canceled workflow can be resumed by RetryWorkflow.
+func (rq *RedisQueue) CancelWorkflow(initialCtx context.Context, runID string) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer("grq").Start(initialCtx, "redisQueue.CancelWorkflow",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("queue", rq.name),
+			attribute.String("workflow.id", runID),
+		),
+	)
+	attachCodeLocationToSpan(span)
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+	var pending []WorkflowNode
+	err = rq.updateWorkflow(ctx, runID, func(pipe redis.Pipeliner, run *WorkflowRun) error {
+		if run.Status == WorkflowSucceeded {
+			return fmt.Errorf("workflow %s is already succeeded", runID)
+		}
+		run.Status = WorkflowCanceled
+		run.UpdatedAt = time.Now()
+		pending = pending[:0]
+		for i := range run.Nodes {
+			if run.Nodes[i].Status == NodePending {
+				pending = append(pending, run.Nodes[i])
+			}
+		}
+		return saveWorkflow(ctx, pipe, run)
+	})
+	if err != nil {
+		return
+	}
+	for _, node := range pending {
+		// outcomes of canceled nodes are recorded by Cancel or by consumers running them
+		_, err = rq.sibling(node.Queue).Cancel(ctx, node.TaskID)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// updateWorkflow applies change to state of workflow run, retrying it, if state was changed concurrently
+func (rq *RedisQueue) updateWorkflow(ctx context.Context, runID string, change func(pipe redis.Pipeliner, run *WorkflowRun) error) (err error) {
+	for range recordAttempts {
+		err = rq.client.Watch(ctx, func(tx *redis.Tx) error {
+			var run WorkflowRun
+			found, errL := loadWorkflow(ctx, tx, runID, &run)
+			if errL != nil {
+				return errL
+			}
+			if !found {
+				return ErrWorkflowNotFound
+			}
+			_, errL = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return change(pipe, &run)
+			})
+			return errL
+		}, workflowKey(runID))
+		if err != redis.TxFailedErr {
+			return
+		}
+	}
+	return fmt.Errorf("%w : while updating workflow %s", err, runID)
+}
+
+// loadWorkflow loads state of workflow run with id provided
+func loadWorkflow(ctx context.Context, c redis.Cmdable, runID string, run *WorkflowRun) (found bool, err error) {
+	data, err := c.Get(ctx, workflowKey(runID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return
+	}
+	err = json.Unmarshal(data, run)
+	if err != nil {
+		return false, fmt.Errorf("%w : while parsing state of workflow %s", err, runID)
+	}
+	return true, nil
+}
+
+// saveWorkflow saves state of workflow run, and state of finished run is kept for DefaultWorkflowTTL
+func saveWorkflow(ctx context.Context, pipe redis.Pipeliner, run *WorkflowRun) (err error) {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return
+	}
+	ttl := time.Duration(0)
+	if run.Status != WorkflowRunning {
+		ttl = DefaultWorkflowTTL
+	}
+	pipe.Set(ctx, workflowKey(run.ID), data, ttl)
+	return nil
+}
+
+// startReadyNodes publishes waiting nodes of workflow run, which dependencies succeeded, updates status of run
+// and saves its state
+func (rq *RedisQueue) startReadyNodes(ctx context.Context, pipe redis.Pipeliner, run *WorkflowRun) (started int, err error) {
+	succeeded := make(map[string]bool, len(run.Nodes))
+	for i := range run.Nodes {
+		succeeded[run.Nodes[i].Name] = run.Nodes[i].Status == NodeSucceeded
+	}
+	var queues []string
+	for i := range run.Nodes {
+		node := &run.Nodes[i]
+		if node.Status != NodeWaiting || slices.ContainsFunc(node.DependsOn, func(parent string) bool {
+			return !succeeded[parent]
+		}) {
+			continue
+		}
+		var task Task
+		task, err = rq.newTask(node.Payload)
+		if err != nil {
+			return
+		}
+		task.Headers = map[string]string{
+			HeaderWorkflow:     run.ID,
+			HeaderWorkflowNode: node.Name,
+		}
+		var raw string
+		raw, err = task.encode()
+		if err != nil {
+			return
+		}
+		node.TaskID = task.ID
+		node.Status = NodePending
+		pipe.RPush(ctx, node.Queue, raw)
+		if !slices.Contains(queues, node.Queue) {
+			queues = append(queues, node.Queue)
+		}
+		started++
+	}
+	for _, queue := range queues {
+		pipe.Publish(ctx, ChannelPrefix+queue, "1")
+	}
+	if !slices.ContainsFunc(run.Nodes, func(node WorkflowNode) bool {
+		return node.Status != NodeSucceeded
+	}) {
+		run.Status = WorkflowSucceeded
+	}
+	run.UpdatedAt = time.Now()
+	return started, saveWorkflow(ctx, pipe, run)
+}
+
+// loadWorkflowOf loads state of workflow run, which task is node of. Nil is returned, if task is not node
+// of workflow, or workflow is expired.
+func (rq *RedisQueue) loadWorkflowOf(ctx context.Context, c redis.Cmdable, task Task) (run *WorkflowRun, err error) {
+	runID := task.Headers[HeaderWorkflow]
+	if runID == "" {
+		return nil, nil
+	}
+	run = &WorkflowRun{}
+	found, err := loadWorkflow(ctx, c, runID, run)
+	if err != nil || !found {
+		return nil, err
+	}
+	return
+}
+
+// recordWorkflow records outcome of node of workflow run, and publishes nodes, that depend on it, if node succeeded,
+// or fails workflow, if node failed. Repeated deliveries of nodes, that are already finished, are ignored.
+func (rq *RedisQueue) recordWorkflow(ctx context.Context, pipe redis.Pipeliner, run *WorkflowRun, task Task, result TaskResult) (err error) {
+	i := slices.IndexFunc(run.Nodes, func(node WorkflowNode) bool {
+		return node.Name == task.Headers[HeaderWorkflowNode]
+	})
+	if i < 0 || run.Nodes[i].TaskID != task.ID || run.Nodes[i].Status != NodePending {
+		return nil
+	}
+	run.Nodes[i].Status = NodeStatus(result.Status)
+	run.Nodes[i].Error = result.Error
+	run.UpdatedAt = result.FinishedAt
+	if run.Status != WorkflowRunning {
+		return saveWorkflow(ctx, pipe, run)
+	}
+	if result.Status != ResultSucceeded {
+		run.Status = WorkflowFailed
+		return saveWorkflow(ctx, pipe, run)
+	}
+	_, err = rq.startReadyNodes(ctx, pipe, run)
+	return
+}
+
+// sibling returns client of queue provided, which shares connection with this client.
+// It is used to publish and cancel tasks of other queues only.
+func (rq *RedisQueue) sibling(queue string) *RedisQueue {
+	if queue == rq.name {
+		return rq
+	}
+	return &RedisQueue{
+		name:      queue,
+		options:   rq.options,
+		heartbeat: rq.heartbeat,
+		timeout:   rq.timeout,
+		id:        rq.id,
+		resultTTL: rq.resultTTL,
+		client:    rq.client,
+	}
+}
@@ -0,0 +1,222 @@
+package grq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedisQueue_PublishWorkflow(t *testing.T) {
+	queues := []string{"testWorkflowA", "testWorkflowB"}
+	publisher, err := New(t.Context(), queues[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	mu := sync.Mutex{}
+	var processed []string
+	flaky := 0
+	cc, cancel := context.WithTimeout(t.Context(), 15*time.Second)
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for _, name := range queues {
+		rq, errC := New(t.Context(), name)
+		if errC != nil {
+			t.Fatal(errC)
+		}
+		defer rq.Close()
+		errC = rq.Purge(t.Context())
+		if errC != nil {
+			t.Error(errC)
+		}
+		rq.SetHeartbeat(100 * time.Millisecond)
+		rq.SetMaxAttempts(1)
+		wg.Go(func() {
+			errW := rq.ConsumeConcurrently(cc, func(ctx context.Context, payload string, indx int) error {
+				switch payload {
+				case "slow":
+					time.Sleep(200 * time.Millisecond)
+				case "flaky":
+					mu.Lock()
+					flaky++
+					attempt := flaky
+					mu.Unlock()
+					if attempt == 1 {
+						return fmt.Errorf("something is wrong")
+					}
+				case "block":
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				mu.Lock()
+				processed = append(processed, payload)
+				mu.Unlock()
+				return nil
+			}, 2)
+			if errW != nil && !errors.Is(errW, context.Canceled) {
+				t.Error(errW)
+			}
+		})
+	}
+
+	diamond, err := publisher.PublishWorkflow(t.Context(),
+		WorkflowNode{Name: "d", Queue: queues[0], Payload: "join", DependsOn: []string{"b", "c"}},
+		WorkflowNode{Name: "a", Queue: queues[0], Payload: "split"},
+		WorkflowNode{Name: "b", Queue: queues[1], Payload: "slow", DependsOn: []string{"a"}},
+		WorkflowNode{Name: "c", Queue: queues[0], Payload: "fast", DependsOn: []string{"a"}},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	run := waitWorkflow(t, publisher, diamond)
+	if run.Status != WorkflowSucceeded {
+		t.Errorf("wrong status %s of workflow", run.Status)
+	}
+	for _, node := range run.Nodes {
+		if node.Status != NodeSucceeded || node.TaskID == "" {
+			t.Errorf("wrong state %v of node %s", node, node.Name)
+		}
+	}
+	mu.Lock()
+	if fmt.Sprint(processed) != "[split fast slow join]" {
+		t.Errorf("wrong order of nodes %v", processed)
+	}
+	processed = nil
+	mu.Unlock()
+
+	failed, err := publisher.PublishWorkflow(t.Context(),
+		WorkflowNode{Name: "first", Queue: queues[1], Payload: "flaky"},
+		WorkflowNode{Name: "second", Queue: queues[0], Payload: "after", DependsOn: []string{"first"}},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	run = waitWorkflow(t, publisher, failed)
+	if run.Status != WorkflowFailed {
+		t.Errorf("wrong status %s of failed workflow", run.Status)
+	}
+	if run.Nodes[0].Status != NodeFailed || run.Nodes[0].Error != "something is wrong" || run.Nodes[1].Status != NodeWaiting {
+		t.Errorf("wrong nodes %v of failed workflow", run.Nodes)
+	}
+	retried, err := publisher.RetryWorkflow(t.Context(), failed)
+	if err != nil {
+		t.Error(err)
+	}
+	if retried != 1 {
+		t.Errorf("%v nodes are retried instead of 1", retried)
+	}
+	run = waitWorkflow(t, publisher, failed)
+	if run.Status != WorkflowSucceeded {
+		t.Errorf("wrong status %s of retried workflow", run.Status)
+	}
+	mu.Lock()
+	if fmt.Sprint(processed) != "[flaky after]" {
+		t.Errorf("wrong nodes %v are processed after retry", processed)
+	}
+	processed = nil
+	mu.Unlock()
+	_, err = publisher.RetryWorkflow(t.Context(), failed)
+	if err == nil {
+		t.Errorf("succeeded workflow is retried")
+	}
+
+	canceled, err := publisher.PublishWorkflow(t.Context(),
+		WorkflowNode{Name: "running", Queue: queues[1], Payload: "block"},
+		WorkflowNode{Name: "next", Queue: queues[0], Payload: "never", DependsOn: []string{"running"}},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		n, errN := publisher.sibling(queues[1]).Count(t.Context())
+		if errN != nil {
+			t.Error(errN)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	err = publisher.CancelWorkflow(t.Context(), canceled)
+	if err != nil {
+		t.Error(err)
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		run, err = publisher.GetWorkflow(t.Context(), canceled)
+		if err != nil {
+			t.Error(err)
+			break
+		}
+		if run.Nodes[0].Status != NodePending {
+			break
+		}
+	}
+	if run.Status != WorkflowCanceled || run.Nodes[0].Status != NodeCanceled || run.Nodes[1].Status != NodeWaiting {
+		t.Errorf("wrong state %v of canceled workflow", run)
+	}
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	if slices.Contains(processed, "never") {
+		t.Errorf("node is processed after workflow is canceled")
+	}
+	mu.Unlock()
+
+	_, err = publisher.GetWorkflow(t.Context(), "unknown")
+	if !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("wrong error %v for unknown workflow", err)
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestRedisQueue_PublishWorkflowInvalid(t *testing.T) {
+	rq, err := New(t.Context(), "testWorkflowInvalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rq.Close()
+	for name, nodes := range map[string][]WorkflowNode{
+		"empty":     nil,
+		"no queue":  {{Name: "a"}},
+		"duplicate": {{Name: "a", Queue: "q"}, {Name: "a", Queue: "q"}},
+		"unknown":   {{Name: "a", Queue: "q", DependsOn: []string{"b"}}},
+		"cycle": {
+			{Name: "a", Queue: "q"},
+			{Name: "b", Queue: "q", DependsOn: []string{"a", "c"}},
+			{Name: "c", Queue: "q", DependsOn: []string{"b"}},
+		},
+	} {
+		_, err = rq.PublishWorkflow(t.Context(), nodes...)
+		if err == nil {
+			t.Errorf("invalid workflow %s is published", name)
+		}
+	}
+	n, err := rq.client.LLen(t.Context(), "q").Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("%v nodes of invalid workflows are published", n)
+	}
+}
+
+// waitWorkflow waits until workflow run is not running
+func waitWorkflow(t *testing.T, rq *RedisQueue, runID string) (run WorkflowRun) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		run, err = rq.GetWorkflow(t.Context(), runID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if run.Status != WorkflowRunning {
+			return
+		}
+	}
+	t.Errorf("workflow %s is not finished", runID)
+	return
+}